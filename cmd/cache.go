@@ -0,0 +1,137 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	nova_helm "github.com/fairwindsops/nova/pkg/helm"
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	cacheCmd.AddCommand(cachePullCmd, cacheInfoCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage offline cache bundles.",
+	Long:  "Build and inspect bundles of the ArtifactHub package list and chart repository indexes, for use with `nova find --cache-bundle` where ArtifactHub cannot be reached.",
+}
+
+var cachePullCmd = &cobra.Command{
+	Use:   "pull <bundle>",
+	Short: "Download the ArtifactHub package list and chart repository indexes into a bundle.",
+	Long:  "Download the ArtifactHub package list and the index of every chart repository given with --url or in the config file into a tar.gz bundle.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		bundle := nova_helm.NewBundle(version)
+		if viper.GetBool("poll-artifacthub") {
+			hubs, err := getHubConfigs()
+			if err != nil {
+				klog.Exit(err)
+			}
+			var lists []nova_helm.HubPackageList
+			var fetchedAt time.Time
+			for _, hub := range hubs {
+				ahClient, err := nova_helm.NewArtifactHubCachedPackageClientForHub(version, hub)
+				if err != nil {
+					klog.Exitf("error setting up artifact hub client for %s: %s", hub.Name, err)
+				}
+				ahClient.Cache = getHTTPCache("artifacthub", viper.GetDuration("artifacthub-cache-max-age"))
+				data, err := ahClient.FetchPackageList()
+				if err != nil {
+					klog.Exitf("error getting artifacthub package list from %s: %v", hub.Name, err)
+				}
+				lists = append(lists, nova_helm.HubPackageList{Hub: hub.Name, Data: data})
+				if fetchedAt.IsZero() || ahClient.FetchedAt.Before(fetchedAt) {
+					fetchedAt = ahClient.FetchedAt
+				}
+			}
+			data, err := nova_helm.MergePackageLists(lists)
+			if err != nil {
+				klog.Exit(err)
+			}
+			err = bundle.SetPackages(data, fetchedAt)
+			if err != nil {
+				klog.Exit(err)
+			}
+		}
+
+		repoConfigs, err := getRepoConfigs()
+		if err != nil {
+			klog.Exit(err)
+		}
+		cache := getHTTPCache("repositories", viper.GetDuration("cache-ttl"))
+		for _, config := range repoConfigs {
+			if nova_helm.IsOCIRepo(config.URL) {
+				klog.Warningf("skipping %s, OCI repositories cannot be added to a bundle", config.URL)
+				continue
+			}
+			response, err := nova_helm.FetchRepoIndex(config, cache)
+			if err != nil {
+				klog.Exit(err)
+			}
+			bundle.AddIndex(config.URL, response.Body, response.FetchedAt)
+		}
+
+		err = bundle.WriteFile(args[0])
+		if err != nil {
+			klog.Exitf("error writing bundle: %v", err)
+		}
+		fmt.Printf("Wrote %d ArtifactHub packages and %d chart repositories to %s\n", bundle.Manifest.PackageCount, len(bundle.Manifest.Repositories), args[0])
+	},
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info <bundle>",
+	Short: "Show the age and contents of a bundle.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		bundle, err := nova_helm.ReadBundle(args[0])
+		if err != nil {
+			klog.Exit(err)
+		}
+		age := time.Since(bundle.Manifest.CreatedAt).Round(time.Second).String()
+		if viper.GetString("format") == output.JSONFormat {
+			data, err := json.MarshalIndent(struct {
+				nova_helm.BundleManifest
+				Age string `json:"age"`
+			}{bundle.Manifest, age}, "", "  ")
+			if err != nil {
+				klog.Exit(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 4, ' ', 0)
+		fmt.Fprintf(w, "Created:\t%s (%s ago)\n", bundle.Manifest.CreatedAt.Format(time.RFC3339), age)
+		fmt.Fprintf(w, "Nova Version:\t%s\n", bundle.Manifest.NovaVersion)
+		fmt.Fprintf(w, "ArtifactHub Packages:\t%d\n", bundle.Manifest.PackageCount)
+		fmt.Fprintf(w, "Chart Repositories:\t%d\n", len(bundle.Manifest.Repositories))
+		for _, repo := range bundle.Manifest.Repositories {
+			fmt.Fprintf(w, "  %s\t%s\n", repo.URL, repo.FetchedAt.Format(time.RFC3339))
+		}
+		w.Flush()
+	},
+}
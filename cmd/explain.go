@@ -0,0 +1,123 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
+
+// releaseExplanation is the json output of the explain command for a single release
+type releaseExplanation struct {
+	ReleaseName string            `json:"release"`
+	Namespace   string            `json:"namespace"`
+	ChartName   string            `json:"chartName"`
+	Version     string            `json:"version"`
+	Match       *output.MatchInfo `json:"match"`
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <release>",
+	Short: "Explain which artifacthub package was matched to a release.",
+	Long:  "List every artifacthub package that was considered for a helm release, the scoring criteria each one matched and its final score.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		h := newHelm(viper.GetString("context"), viper.GetString("kubeconfig"))
+		releases, err := h.GetHelmReleases(viper.GetString("namespace"), nil, nil)
+		if err != nil {
+			klog.Exitf("error getting helm releases: %s", err)
+		}
+		bundle, err := getCacheBundle()
+		if err != nil {
+			klog.Exit(err)
+		}
+		packages, _, err := getArtifactHubPackages(bundle)
+		if err != nil {
+			klog.Exit(err)
+		}
+
+		var explanations []releaseExplanation
+		for _, release := range releases {
+			if release.Name != args[0] {
+				continue
+			}
+			match, _ := h.Matcher.Explain(release, packages)
+			explanations = append(explanations, releaseExplanation{
+				ReleaseName: release.Name,
+				Namespace:   release.Namespace,
+				ChartName:   release.Chart.Metadata.Name,
+				Version:     release.Chart.Metadata.Version,
+				Match:       match,
+			})
+		}
+		if len(explanations) == 0 {
+			klog.Exitf("release %s not found", args[0])
+		}
+
+		if viper.GetString("format") == output.JSONFormat {
+			data, err := json.MarshalIndent(explanations, "", "  ")
+			if err != nil {
+				klog.Exit(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		for _, e := range explanations {
+			printExplanation(e)
+		}
+	},
+}
+
+func printExplanation(e releaseExplanation) {
+	fmt.Printf("Release %s in namespace %s, chart %s version %s\n\n", e.ReleaseName, e.Namespace, e.ChartName, e.Version)
+	if e.Match.PinnedRepository != "" {
+		fmt.Printf("Pinned to artifacthub repository %s, candidates are not scored.\n\n", e.Match.PinnedRepository)
+	}
+	if len(e.Match.Candidates) == 0 {
+		fmt.Printf("No artifacthub packages named %s were found.\n\n", e.ChartName)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 4, ' ', 0)
+	fmt.Fprintln(w, "Selected\tRepository\tVersion\tStars\tStar Bonus\tScore\tCriteria\t")
+	fmt.Fprintln(w, "========\t==========\t=======\t=====\t==========\t=====\t========\t")
+	for _, c := range e.Match.Candidates {
+		criteria := make([]string, len(c.Criteria))
+		for i, criterion := range c.Criteria {
+			criteria[i] = fmt.Sprintf("%s(+%g)", criterion.Name, criterion.Score)
+		}
+		selected := ""
+		if c.Selected {
+			selected = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%g\t%g\t%s\t\n", selected, c.Repository, c.Version, c.Stars, c.StarBonus, c.Score, strings.Join(criteria, ", "))
+	}
+	w.Flush()
+	if e.Match.Ambiguous {
+		fmt.Println("\nThe match is ambiguous, the runner-up scored close to the selected package.")
+	}
+	fmt.Println("")
+}
@@ -23,6 +23,8 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -37,6 +39,9 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// sortByVulnerabilities is the --sort-by value that orders releases by their known vulnerabilities
+const sortByVulnerabilities = "vulnerabilities"
+
 var (
 	version       string
 	versionCommit string
@@ -69,7 +74,7 @@ func init() {
 		klog.Exitf("Failed to bind format flag: %v", err)
 	}
 
-	rootCmd.PersistentFlags().StringToStringP("desired-versions", "d", nil, "A map of chart=override_version to override the helm repository when checking.")
+	rootCmd.PersistentFlags().StringToStringP("desired-versions", "d", nil, "A map of chart=override_version to override the helm repository when checking. The version can be a semver constraint, like ~1.4 or ^3.0.0, to use the newest version that satisfies it.")
 	err = viper.BindPFlag("desired-versions", rootCmd.PersistentFlags().Lookup("desired-versions"))
 	if err != nil {
 		klog.Exitf("Failed to bind desired-versions flag: %v", err)
@@ -81,6 +86,84 @@ func init() {
 		klog.Exitf("Failed to bind url flag: %v", err)
 	}
 
+	rootCmd.PersistentFlags().String("helm-repository-config", nova_helm.DefaultHelmRepositoryConfig(), "Path to the helm repositories.yaml file.")
+	err = viper.BindPFlag("helm-repository-config", rootCmd.PersistentFlags().Lookup("helm-repository-config"))
+	if err != nil {
+		klog.Exitf("Failed to bind helm-repository-config flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("use-helm-credentials", false, "Use the credentials and TLS settings from the helm repositories.yaml file for matching chart repos.")
+	err = viper.BindPFlag("use-helm-credentials", rootCmd.PersistentFlags().Lookup("use-helm-credentials"))
+	if err != nil {
+		klog.Exitf("Failed to bind use-helm-credentials flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("helm-repositories", false, "Check releases against all repositories in the helm repositories.yaml file, using helm's cached index files when available.")
+	err = viper.BindPFlag("helm-repositories", rootCmd.PersistentFlags().Lookup("helm-repositories"))
+	if err != nil {
+		klog.Exitf("Failed to bind helm-repositories flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().String("helm-repository-cache", nova_helm.DefaultHelmRepositoryCache(), "Path to the directory containing helm's cached repository index files.")
+	err = viper.BindPFlag("helm-repository-cache", rootCmd.PersistentFlags().Lookup("helm-repository-cache"))
+	if err != nil {
+		klog.Exitf("Failed to bind helm-repository-cache flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Duration("helm-repository-cache-max-age", 0, "Cached helm repository index files older than this are downloaded again. Zero means cached files are always used.")
+	err = viper.BindPFlag("helm-repository-cache-max-age", rootCmd.PersistentFlags().Lookup("helm-repository-cache-max-age"))
+	if err != nil {
+		klog.Exitf("Failed to bind helm-repository-cache-max-age flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().String("cache-dir", nova_helm.DefaultCacheDir(), "Directory to cache downloaded chart repository index files and the ArtifactHub package list in.")
+	err = viper.BindPFlag("cache-dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+	if err != nil {
+		klog.Exitf("Failed to bind cache-dir flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Duration("cache-ttl", time.Hour, "How long cached chart repository index files are used before checking the repository for changes.")
+	err = viper.BindPFlag("cache-ttl", rootCmd.PersistentFlags().Lookup("cache-ttl"))
+	if err != nil {
+		klog.Exitf("Failed to bind cache-ttl flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Duration("artifacthub-cache-max-age", 6*time.Hour, "How long the cached ArtifactHub package list is used before checking ArtifactHub for changes. An expired list is still used if ArtifactHub cannot be reached.")
+	err = viper.BindPFlag("artifacthub-cache-max-age", rootCmd.PersistentFlags().Lookup("artifacthub-cache-max-age"))
+	if err != nil {
+		klog.Exitf("Failed to bind artifacthub-cache-max-age flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Do not read or write cached chart repository index files or the ArtifactHub package list.")
+	err = viper.BindPFlag("no-cache", rootCmd.PersistentFlags().Lookup("no-cache"))
+	if err != nil {
+		klog.Exitf("Failed to bind no-cache flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("refresh", false, "Download chart repository index files and the ArtifactHub package list again, even if they are cached, and update the cache.")
+	err = viper.BindPFlag("refresh", rootCmd.PersistentFlags().Lookup("refresh"))
+	if err != nil {
+		klog.Exitf("Failed to bind refresh flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Duration("artifacthub-timeout", nova_helm.DefaultArtifactHubTimeout, "How long a single request to ArtifactHub may take, including downloading the response.")
+	err = viper.BindPFlag("artifacthub-timeout", rootCmd.PersistentFlags().Lookup("artifacthub-timeout"))
+	if err != nil {
+		klog.Exitf("Failed to bind artifacthub-timeout flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Int("artifacthub-max-retries", nova_helm.DefaultArtifactHubMaxRetries, "How often a request to ArtifactHub that failed with a network error, a rate limit or a server error is retried, with exponential backoff or as long as a Retry-After header asks.")
+	err = viper.BindPFlag("artifacthub-max-retries", rootCmd.PersistentFlags().Lookup("artifacthub-max-retries"))
+	if err != nil {
+		klog.Exitf("Failed to bind artifacthub-max-retries flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Duration("artifacthub-max-retry-wait", nova_helm.DefaultArtifactHubMaxRetryWait, "The longest ArtifactHub may ask to wait with a Retry-After header before a request is retried. Requests asking to wait longer are not retried and a warning is logged.")
+	err = viper.BindPFlag("artifacthub-max-retry-wait", rootCmd.PersistentFlags().Lookup("artifacthub-max-retry-wait"))
+	if err != nil {
+		klog.Exitf("Failed to bind artifacthub-max-retry-wait flag: %v", err)
+	}
+
 	rootCmd.PersistentFlags().Bool("poll-artifacthub", true, "When true, polls artifacthub to match against helm releases in the cluster. If false, you must provide a url list via --url/-u. Default is true.")
 	err = viper.BindPFlag("poll-artifacthub", rootCmd.PersistentFlags().Lookup("poll-artifacthub"))
 	if err != nil {
@@ -144,6 +227,48 @@ func init() {
 		klog.Exitf("Failed to bind show-old flag: %v", err)
 	}
 
+	rootCmd.PersistentFlags().String("min-app-drift", "", "With --show-old, only show charts whose app version is behind the latest by at least this part (patch, minor, major), instead of charts whose chart version is behind.")
+	err = viper.BindPFlag("min-app-drift", rootCmd.PersistentFlags().Lookup("min-app-drift"))
+	if err != nil {
+		klog.Exitf("Failed to bind min-app-drift flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().String("helm-driver", nova_helm.DriverSecret, "The helm storage driver to read releases from (secret, configmap, sql, auto). auto reads from both secrets and configmaps.")
+	err = viper.BindPFlag("helm-driver", rootCmd.PersistentFlags().Lookup("helm-driver"))
+	if err != nil {
+		klog.Exitf("Failed to bind helm-driver flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().String("helm-driver-sql-connection-string", os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING"), "The connection string used by the sql helm driver. Defaults to the HELM_DRIVER_SQL_CONNECTION_STRING environment variable.")
+	err = viper.BindPFlag("helm-driver-sql-connection-string", rootCmd.PersistentFlags().Lookup("helm-driver-sql-connection-string"))
+	if err != nil {
+		klog.Exitf("Failed to bind helm-driver-sql-connection-string flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("all-statuses", false, "Show the latest revision of helm releases in every status (failed, pending-upgrade, superseded, etc.), not just deployed ones.")
+	err = viper.BindPFlag("all-statuses", rootCmd.PersistentFlags().Lookup("all-statuses"))
+	if err != nil {
+		klog.Exitf("Failed to bind all-statuses flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().String("cache-bundle", "", "Path to a bundle created by `nova cache pull`. Its ArtifactHub package list and chart repository indexes are used instead of downloading them.")
+	err = viper.BindPFlag("cache-bundle", rootCmd.PersistentFlags().Lookup("cache-bundle"))
+	if err != nil {
+		klog.Exitf("Failed to bind cache-bundle flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Float64("min-match-score", 0, "Releases whose best artifacthub package scores lower than this are reported as not found. Overrides min-score in the artifacthub-matching config.")
+	err = viper.BindPFlag("min-match-score", rootCmd.PersistentFlags().Lookup("min-match-score"))
+	if err != nil {
+		klog.Exitf("Failed to bind min-match-score flag: %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("include-prereleases", false, "Suggest pre-release versions, like 1.2.0-rc.1, as upgrades for helm charts and container images.")
+	err = viper.BindPFlag("include-prereleases", rootCmd.PersistentFlags().Lookup("include-prereleases"))
+	if err != nil {
+		klog.Exitf("Failed to bind include-prereleases flag: %v", err)
+	}
+
 	findCmd.Flags().StringSlice("release-ignore-list", []string{}, "List of Helm release names to ignore")
 	err = viper.BindPFlag("release-ignore-list", findCmd.Flags().Lookup("release-ignore-list"))
 	if err != nil {
@@ -156,6 +281,42 @@ func init() {
 		klog.Exitf("Failed to bind chart-ignore-list flag: %v", err)
 	}
 
+	findCmd.Flags().Bool("gitops", false, "Also find charts declared by Flux HelmRelease and Argo CD Application objects.")
+	err = viper.BindPFlag("gitops", findCmd.Flags().Lookup("gitops"))
+	if err != nil {
+		klog.Exitf("Failed to bind gitops flag: %v", err)
+	}
+
+	findCmd.Flags().Bool("fail-on-repo-errors", false, "Exit with a non-zero status if any chart repository could not be loaded.")
+	err = viper.BindPFlag("fail-on-repo-errors", findCmd.Flags().Lookup("fail-on-repo-errors"))
+	if err != nil {
+		klog.Exitf("Failed to bind fail-on-repo-errors flag: %v", err)
+	}
+
+	findCmd.Flags().Bool("show-match-details", false, "Include the artifacthub packages considered for each release, and how they scored, in the match field of the JSON output.")
+	err = viper.BindPFlag("show-match-details", findCmd.Flags().Lookup("show-match-details"))
+	if err != nil {
+		klog.Exitf("Failed to bind show-match-details flag: %v", err)
+	}
+
+	findCmd.Flags().Bool("show-vulnerabilities", false, "Fetch the artifacthub security report summaries of the installed and latest versions of releases matched against artifacthub.")
+	err = viper.BindPFlag("show-vulnerabilities", findCmd.Flags().Lookup("show-vulnerabilities"))
+	if err != nil {
+		klog.Exitf("Failed to bind show-vulnerabilities flag: %v", err)
+	}
+
+	findCmd.Flags().String("sort-by", "", "Order the helm releases in the output (vulnerabilities). vulnerabilities implies --show-vulnerabilities and lists the releases with the most critical known vulnerabilities first.")
+	err = viper.BindPFlag("sort-by", findCmd.Flags().Lookup("sort-by"))
+	if err != nil {
+		klog.Exitf("Failed to bind sort-by flag: %v", err)
+	}
+
+	findCmd.Flags().String("target-kube-version", "", "The Kubernetes version, e.g. 1.29, to check chart kubeVersion constraints and release manifests for deprecated APIs against, instead of the version of the cluster.")
+	err = viper.BindPFlag("target-kube-version", findCmd.Flags().Lookup("target-kube-version"))
+	if err != nil {
+		klog.Exitf("Failed to bind target-kube-version flag: %v", err)
+	}
+
 	klog.InitFlags(nil)
 	_ = flag.Set("alsologtostderr", "true")
 	_ = flag.Set("logtostderr", "true")
@@ -247,8 +408,8 @@ var findCmd = &cobra.Command{
 	Short: "Find out-of-date deployed releases.",
 	Long:  "Find deployed helm releases that have updated charts available in chart repos",
 	Run: func(cmd *cobra.Command, args []string) {
-		if !viper.GetBool("poll-artifacthub") && len(viper.GetStringSlice("url")) == 0 {
-			klog.Exitf("--poll-artifacthub=false requires urls provided to the --url flag. none were provided.")
+		if !viper.GetBool("poll-artifacthub") && len(viper.GetStringSlice("url")) == 0 && !viper.IsSet("repositories") && !viper.GetBool("helm-repositories") && viper.GetString("cache-bundle") == "" {
+			klog.Exitf("--poll-artifacthub=false requires urls provided to the --url flag, repositories in the config file, --helm-repositories or --cache-bundle. none were provided.")
 		}
 		klog.V(5).Infof("Settings: %v", viper.AllSettings())
 		klog.V(5).Infof("All Keys: %v", viper.AllKeys())
@@ -260,6 +421,13 @@ var findCmd = &cobra.Command{
 		if !(format == output.TableFormat || format == output.JSONFormat) {
 			klog.Exitf("--format flag value is not valid. Run `nova find --help` to see flag options")
 		}
+		sortBy := viper.GetString("sort-by")
+		if !(sortBy == "" || sortBy == sortByVulnerabilities) {
+			klog.Exitf("--sort-by flag value is not valid. Run `nova find --help` to see flag options")
+		}
+		if minAppDrift := viper.GetString("min-app-drift"); minAppDrift != "" && !output.IsValidAppDrift(minAppDrift) {
+			klog.Exitf("--min-app-drift flag value is not valid. Run `nova find --help` to see flag options")
+		}
 
 		if viper.GetBool("helm") && viper.GetBool("containers") {
 			output, err := handleHelmAndContainers(kubeContext, kubeConfigPath)
@@ -275,6 +443,7 @@ var findCmd = &cobra.Command{
 			} else {
 				output.Print(format, viper.GetBool("wide"), viper.GetBool("show-old"))
 			}
+			exitOnRepoErrors(output.Helm.RepoErrors)
 			return
 		}
 
@@ -300,9 +469,17 @@ var findCmd = &cobra.Command{
 		} else {
 			output.Print(format, viper.GetBool("wide"), viper.GetBool("show-old"))
 		}
+		exitOnRepoErrors(output.RepoErrors)
 	},
 }
 
+// exitOnRepoErrors exits with a non-zero status when --fail-on-repo-errors is set and a chart repository could not be loaded
+func exitOnRepoErrors(repoErrors []output.RepoError) {
+	if viper.GetBool("fail-on-repo-errors") && len(repoErrors) > 0 {
+		klog.Exitf("%d chart repositories could not be loaded", len(repoErrors))
+	}
+}
+
 var genConfigCmd = &cobra.Command{
 	Use:   "generate-config",
 	Short: "Generate a config file.",
@@ -343,6 +520,7 @@ func handleContainers(kubeContext, kubeConfigPath string) (*output.ContainersOut
 		}
 	}()
 	iClient := containers.NewClient(kubeContext, kubeConfigPath)
+	iClient.IncludePrereleases = viper.GetBool("include-prereleases")
 	namespace := viper.GetString("namespace")
 	if viper.IsSet("namespace") {
 		klog.V(3).Infof("Scanning namespace %v", namespace)
@@ -360,20 +538,7 @@ func handleContainers(kubeContext, kubeConfigPath string) (*output.ContainersOut
 }
 
 func handleHelm(kubeContext, kubeConfigPath string) (*output.Output, error) {
-	h := nova_helm.NewHelm(kubeContext, kubeConfigPath)
-	if viper.IsSet("desired-versions") {
-		klog.V(3).Infof("desired-versions is set - attempting to load them")
-		klog.V(8).Infof("raw desired-versions: %v", viper.Get("desired-versions"))
-
-		desiredVersion := viper.GetStringMapString("desired-versions")
-		for k, v := range desiredVersion {
-			klog.V(2).Infof("version override for %s: %s", k, v)
-			h.DesiredVersions = append(h.DesiredVersions, nova_helm.DesiredVersion{
-				Name:    k,
-				Version: v,
-			})
-		}
-	}
+	h := newHelm(kubeContext, kubeConfigPath)
 	namespace := viper.GetString("namespace")
 	if viper.IsSet("namespace") {
 		klog.V(3).Infof("Scanning namespace %v", namespace)
@@ -386,35 +551,342 @@ func handleHelm(kubeContext, kubeConfigPath string) (*output.Output, error) {
 	}
 	out := output.NewOutputWithHelmReleases(releases)
 	out.IncludeAll = viper.GetBool("include-all")
+	out.AllStatuses = h.AllStatuses
+
+	bundle, err := getCacheBundle()
+	if err != nil {
+		return nil, err
+	}
 
+	var packages []nova_helm.ArtifactHubHelmPackage
 	if viper.GetBool("poll-artifacthub") {
-		ahClient, err := nova_helm.NewArtifactHubCachedPackageClient(version)
-		if err != nil {
-			return nil, fmt.Errorf("error setting up artifact hub client: %s", err)
-		}
-		packages, err := ahClient.List()
+		packages, out.ArtifactHubCache, err = getArtifactHubPackages(bundle)
 		if err != nil {
-			return nil, fmt.Errorf("error getting artifacthub package repos: %v", err)
+			return nil, err
 		}
-		klog.V(2).Infof("found %d possible package matches", len(packages))
 		for _, release := range releases {
-			o := nova_helm.FindBestArtifactHubMatch(release, packages)
+			o := h.Matcher.FindBestMatch(release, packages)
 			if o != nil {
 				h.OverrideDesiredVersion(o)
 				out.HelmReleases = append(out.HelmReleases, *o)
 			}
 		}
 	}
-	if len(viper.GetStringSlice("url")) > 0 {
-		repos := viper.GetStringSlice("url")
-		helmRepos := nova_helm.NewRepos(repos)
+	repoConfigs, err := getRepoConfigs()
+	if err != nil {
+		return nil, err
+	}
+	// gitops objects may declare the configured repositories, whose credentials are used to load them
+	credentialConfigs := repoConfigs
+	var helmRepos []*nova_helm.Repo
+	if bundle != nil {
+		bundled, unbundled := bundle.Repos(repoConfigs)
+		klog.V(3).Infof("loaded %d chart repositories from the cache bundle", len(bundled))
+		helmRepos = append(helmRepos, bundled...)
+		repoConfigs = unbundled
+	}
+	if viper.GetBool("helm-repositories") {
+		helmConfigs, err := nova_helm.LoadHelmRepositoryConfigs(viper.GetString("helm-repository-config"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading helm repositories file: %s", err)
+		}
+		cached, uncached := nova_helm.NewReposFromHelmCache(helmConfigs, viper.GetString("helm-repository-cache"), viper.GetDuration("helm-repository-cache-max-age"))
+		klog.V(3).Infof("loaded %d of %d helm repositories from the cache", len(cached), len(helmConfigs))
+		helmRepos = append(helmRepos, cached...)
+		repoConfigs = appendRepoConfigs(repoConfigs, uncached)
+		credentialConfigs = appendRepoConfigs(credentialConfigs, helmConfigs)
+	}
+	if len(repoConfigs) > 0 {
+		loaded, repoErrors := nova_helm.NewReposFromConfig(repoConfigs, getHTTPCache("repositories", viper.GetDuration("cache-ttl")))
+		helmRepos = append(helmRepos, loaded...)
+		out.RepoErrors = repoErrors
+	}
+	if len(helmRepos) > 0 {
 		outputObjects := h.GetHelmReleasesVersion(helmRepos, releases)
 		out.HelmReleases = append(out.HelmReleases, outputObjects...)
 	}
+	if viper.GetBool("gitops") {
+		gitopsReleases, err := h.GetGitOpsReleases(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting gitops releases: %s", err)
+		}
+		if viper.GetBool("use-helm-credentials") && !viper.GetBool("helm-repositories") {
+			helmConfigs, err := nova_helm.LoadHelmRepositoryConfigs(viper.GetString("helm-repository-config"))
+			if err != nil {
+				return nil, fmt.Errorf("error reading helm repositories file: %s", err)
+			}
+			credentialConfigs = appendRepoConfigs(credentialConfigs, helmConfigs)
+		}
+		gitopsOutput, repoErrors := h.GetGitOpsReleasesVersion(gitopsReleases, packages, credentialConfigs, getHTTPCache("repositories", viper.GetDuration("cache-ttl")))
+		out.MergeGitOpsReleases(gitopsOutput)
+		for _, repoError := range repoErrors {
+			if !slices.ContainsFunc(out.RepoErrors, func(e output.RepoError) bool { return e.URL == repoError.URL }) {
+				out.RepoErrors = append(out.RepoErrors, repoError)
+			}
+		}
+	}
 	out.Dedupe()
+	out.MinAppDrift = viper.GetString("min-app-drift")
+	out.Wide = viper.GetBool("wide")
+	for i := range out.HelmReleases {
+		out.HelmReleases[i].SetAppDrift()
+	}
+	var kubeVersion string
+	if target := viper.GetString("target-kube-version"); target != "" {
+		kubeVersion, err = nova_helm.NormalizeKubeVersion(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --target-kube-version: %v", err)
+		}
+	} else {
+		kubeVersion, err = h.ServerVersion()
+		if err != nil {
+			klog.Warningf("skipping the kubernetes version compatibility and deprecated API checks: %v", err)
+		}
+	}
+	if kubeVersion != "" {
+		out.KubeVersion = kubeVersion
+		for i := range out.HelmReleases {
+			nova_helm.SetKubeCompatibility(&out.HelmReleases[i], kubeVersion)
+		}
+		nova_helm.AddDeprecatedAPIs(out.HelmReleases, releases, kubeVersion)
+	}
+	if viper.GetBool("show-vulnerabilities") || viper.GetString("sort-by") == sortByVulnerabilities {
+		hubs, err := getHubConfigs()
+		if err != nil {
+			return nil, err
+		}
+		for _, hub := range hubs {
+			ahClient, err := nova_helm.NewArtifactHubPackageClientForHub(version, hub)
+			if err != nil {
+				return nil, fmt.Errorf("error setting up artifact hub client for %s: %s", hub.Name, err)
+			}
+			ahClient.AddSecurityReports(out.HelmReleases)
+		}
+		out.Vulnerabilities = true
+	}
+	if viper.GetString("sort-by") == sortByVulnerabilities {
+		out.SortByVulnerabilities()
+	}
+	if !viper.GetBool("show-match-details") {
+		for i := range out.HelmReleases {
+			out.HelmReleases[i].Match = nil
+		}
+	}
 	return &out, nil
 }
 
+// newHelm returns a helm client configured from the flags and config file
+func newHelm(kubeContext, kubeConfigPath string) *nova_helm.Helm {
+	h := nova_helm.NewHelm(kubeContext, kubeConfigPath)
+	h.Driver = viper.GetString("helm-driver")
+	h.SQLConnectionString = viper.GetString("helm-driver-sql-connection-string")
+	h.AllStatuses = viper.GetBool("all-statuses")
+	h.IncludePrereleases = viper.GetBool("include-prereleases")
+	h.Matcher = getArtifactHubMatcher()
+	if viper.IsSet("desired-versions") {
+		klog.V(3).Infof("desired-versions is set - attempting to load them")
+		klog.V(8).Infof("raw desired-versions: %v", viper.Get("desired-versions"))
+
+		// the config file may list desired versions scoped to namespaces and releases instead of a chart=version map
+		if _, ok := viper.Get("desired-versions").([]interface{}); ok {
+			err := viper.UnmarshalKey("desired-versions", &h.DesiredVersions)
+			if err != nil {
+				klog.Exitf("error reading desired-versions from config: %s", err)
+			}
+		} else {
+			desiredVersion := viper.GetStringMapString("desired-versions")
+			for k, v := range desiredVersion {
+				h.DesiredVersions = append(h.DesiredVersions, nova_helm.DesiredVersion{
+					Name:    k,
+					Version: v,
+				})
+			}
+		}
+		for _, d := range h.DesiredVersions {
+			if err := d.Validate(); err != nil {
+				klog.Exitf("invalid desired-versions: %s", err)
+			}
+			klog.V(2).Infof("version override for %+v", d)
+		}
+	}
+	return h
+}
+
+// getArtifactHubMatcher returns the artifacthub matcher configured in the config file
+func getArtifactHubMatcher() *nova_helm.ArtifactHubMatcher {
+	matcher := &nova_helm.ArtifactHubMatcher{}
+	if viper.IsSet("artifacthub-matching") {
+		err := viper.UnmarshalKey("artifacthub-matching", matcher)
+		if err != nil {
+			klog.Exitf("error reading artifacthub-matching from config: %s", err)
+		}
+	}
+	matcher.IncludePrereleases = viper.GetBool("include-prereleases")
+	if viper.IsSet("min-match-score") {
+		matcher.MinScore = float32(viper.GetFloat64("min-match-score"))
+	}
+	if viper.IsSet("artifacthub-pins") {
+		err := viper.UnmarshalKey("artifacthub-pins", &matcher.Pins)
+		if err != nil {
+			klog.Exitf("error reading artifacthub-pins from config: %s", err)
+		}
+	}
+	if err := matcher.Validate(); err != nil {
+		klog.Exitf("invalid artifacthub matching config: %s", err)
+	}
+	return matcher
+}
+
+// getCacheBundle reads the bundle given with --cache-bundle, or returns nil if none was given
+func getCacheBundle() (*nova_helm.Bundle, error) {
+	bundlePath := viper.GetString("cache-bundle")
+	if bundlePath == "" {
+		return nil, nil
+	}
+	bundle, err := nova_helm.ReadBundle(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache bundle: %s", err)
+	}
+	klog.V(3).Infof("using cache bundle %s created at %s", bundlePath, bundle.Manifest.CreatedAt)
+	return bundle, nil
+}
+
+// getArtifactHubPackages returns the artifacthub package list from the bundle, if it has one, or the merged package
+// lists of every configured hub from the cache or the hubs
+func getArtifactHubPackages(bundle *nova_helm.Bundle) ([]nova_helm.ArtifactHubHelmPackage, *output.CacheInfo, error) {
+	hubs, err := getHubConfigs()
+	if err != nil {
+		return nil, nil, err
+	}
+	if bundle != nil && bundle.Packages != nil {
+		hubs = hubs[:1]
+	}
+	var lists [][]nova_helm.ArtifactHubHelmPackage
+	var fetchedAt time.Time
+	var stale bool
+	for _, hub := range hubs {
+		ahClient, err := nova_helm.NewArtifactHubCachedPackageClientForHub(version, hub)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error setting up artifact hub client for %s: %s", hub.Name, err)
+		}
+		ahClient.Cache = getHTTPCache("artifacthub", viper.GetDuration("artifacthub-cache-max-age"))
+		if ahClient.Cache != nil {
+			ahClient.Cache.AllowStale = true
+		}
+		if bundle != nil && bundle.Packages != nil {
+			ahClient.Bundle = bundle
+		}
+		packages, err := ahClient.List()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting artifacthub package repos from %s: %v", hub.Name, err)
+		}
+		lists = append(lists, packages)
+		// The oldest package list determines the age of the merged list
+		if fetchedAt.IsZero() || ahClient.FetchedAt.Before(fetchedAt) {
+			fetchedAt = ahClient.FetchedAt
+		}
+		stale = stale || ahClient.Stale
+	}
+	packages := nova_helm.MergePackages(lists...)
+	cacheInfo := output.NewCacheInfo(fetchedAt, stale)
+	klog.V(2).Infof("found %d possible package matches in artifacthub data fetched %s ago", len(packages), cacheInfo.Age)
+	return packages, cacheInfo, nil
+}
+
+// getHubConfigs returns the artifacthub instances from the config file, or the public artifacthub if none are configured
+func getHubConfigs() ([]nova_helm.HubConfig, error) {
+	hubs := []nova_helm.HubConfig{nova_helm.DefaultHub()}
+	if viper.IsSet("artifacthubs") {
+		hubs = nil
+		err := viper.UnmarshalKey("artifacthubs", &hubs)
+		if err != nil {
+			return nil, fmt.Errorf("error reading artifacthubs from config: %s", err)
+		}
+		if len(hubs) == 0 {
+			return nil, fmt.Errorf("artifacthubs in the config file is empty")
+		}
+	}
+	for i := range hubs {
+		if hubs[i].Name == "" {
+			hubs[i].Name = hubs[i].URL
+		}
+		if err := hubs[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid artifacthubs config: %s", err)
+		}
+		hubs[i].Timeout = viper.GetDuration("artifacthub-timeout")
+		hubs[i].MaxRetries = viper.GetInt("artifacthub-max-retries")
+		hubs[i].MaxRetryWait = viper.GetDuration("artifacthub-max-retry-wait")
+	}
+	return hubs, nil
+}
+
+// getRepoConfigs combines the --url flag with the repositories from the config file. Repositories in the config file
+// take precedence over urls with the same address.
+func getRepoConfigs() ([]nova_helm.RepoConfig, error) {
+	var configured []nova_helm.RepoConfig
+	if viper.IsSet("repositories") {
+		err := viper.UnmarshalKey("repositories", &configured)
+		if err != nil {
+			return nil, fmt.Errorf("error reading repositories from config: %s", err)
+		}
+	}
+	configs := []nova_helm.RepoConfig{}
+	seen := map[string]bool{}
+	for _, c := range configured {
+		c.URL = strings.TrimSuffix(c.URL, "/")
+		if c.URL == "" || seen[c.URL] {
+			continue
+		}
+		seen[c.URL] = true
+		configs = append(configs, c)
+	}
+	for _, u := range viper.GetStringSlice("url") {
+		u = strings.TrimSuffix(u, "/")
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		configs = append(configs, nova_helm.RepoConfig{URL: u})
+	}
+	if viper.GetBool("use-helm-credentials") && len(configs) > 0 {
+		helmConfigs, err := nova_helm.LoadHelmRepositoryConfigs(viper.GetString("helm-repository-config"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading helm repositories file: %s", err)
+		}
+		configs = nova_helm.ApplyHelmCredentials(configs, helmConfigs)
+	}
+	return configs, nil
+}
+
+// appendRepoConfigs adds repositories that are not already configured
+func appendRepoConfigs(configs []nova_helm.RepoConfig, additional []nova_helm.RepoConfig) []nova_helm.RepoConfig {
+	for _, a := range additional {
+		found := false
+		for _, c := range configs {
+			if strings.TrimSuffix(c.URL, "/") == strings.TrimSuffix(a.URL, "/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			configs = append(configs, a)
+		}
+	}
+	return configs
+}
+
+// getHTTPCache returns a cache in a subdirectory of the cache dir, or nil if caching is disabled
+func getHTTPCache(subdir string, ttl time.Duration) *nova_helm.HTTPCache {
+	if viper.GetBool("no-cache") {
+		return nil
+	}
+	return &nova_helm.HTTPCache{
+		Dir:     filepath.Join(viper.GetString("cache-dir"), subdir),
+		TTL:     ttl,
+		Refresh: viper.GetBool("refresh"),
+	}
+}
+
 func handleHelmAndContainers(kubeContext, kubeConfigPath string) (*output.HelmAndContainersOutput, error) {
 	helmOutput, err := handleHelm(kubeContext, kubeConfigPath)
 	if err != nil {
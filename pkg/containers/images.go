@@ -26,6 +26,7 @@ import (
 	version "github.com/Masterminds/semver/v3"
 	"github.com/fairwindsops/controller-utils/pkg/controller"
 	"github.com/fairwindsops/nova/pkg/kube"
+	"github.com/fairwindsops/nova/pkg/versions"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -35,11 +36,11 @@ import (
 	"k8s.io/klog/v2"
 )
 
-var preReleaseIgnore = []string{"alpha", "beta", "rc", "snapshot", "dev", "prerelease", "pre"}
-
 // Client represents a kubernetes client. Having a struct around this allows us to implement a fake client in tests
 type Client struct {
 	Kube *kube.Connection
+	// IncludePrereleases suggests pre-release tags, like 1.2.4-rc.1, as upgrades
+	IncludePrereleases bool
 }
 
 // Results is a struct that contains a list of Images and a list of ErroredImages. This is the main thing that is returned from this package
@@ -56,18 +57,20 @@ type ErroredImage struct {
 
 // Image contains all the relevant data for reporting an out of date image
 type Image struct {
-	Name          string
-	Prefix        string
-	Current       *Tag
-	Newest        *Tag
-	NewestPatch   *Tag
-	NewestMinor   *Tag
-	StrictSemver  bool
-	semverTags    []*version.Version
-	nonSemverTags []string
-	repo          name.Repository
-	allTags       []string
-	WorkLoads     []Workload
+	Name         string
+	Prefix       string
+	Current      *Tag
+	Newest       *Tag
+	NewestPatch  *Tag
+	NewestMinor  *Tag
+	StrictSemver bool
+	// IncludePrereleases suggests pre-release tags, like 1.2.4-rc.1, as upgrades
+	IncludePrereleases bool
+	semverTags         []*version.Version
+	nonSemverTags      []string
+	repo               name.Repository
+	allTags            []string
+	WorkLoads          []Workload
 }
 
 // Workload contains all the relevant data for the container workload
@@ -121,6 +124,7 @@ func (c *Client) Find(ctx context.Context, namespace string) (*Results, error) {
 			})
 			continue
 		}
+		image.IncludePrereleases = c.IncludePrereleases
 		klog.V(8).Infof("Getting tags for %s", image.Name)
 		wg.Add(1)
 		go func(fullName string) {
@@ -296,19 +300,22 @@ func (i *Image) populateNewest() error {
 	}
 	klog.V(3).Infof("Populating newest tags for %s", i.Name)
 	newerTags := make([]*version.Version, 0)
-	constraint, err := version.NewConstraint(fmt.Sprintf("> %s", i.Current.version.String()))
-	if err != nil {
-		return errors.Wrap(err, "failed to create constraint")
-	}
 	// The goal of the filter below is to find things like "1.2.3-alpine" or "1.2.3-buster" and make sure we only give upgrade suggestions that match.
-	// There may be unintended consequenses from this. We do our best by ignoring actual common pre-release tags in the variable preReleaseIgnore.
-	// This means if you are currently running 1.2.3-beta.0 the filter will not limit the upgrade suggestions to other beta releases.
+	// Pre-releases like "1.2.4-rc.1" are only suggested when IncludePrereleases is set, and running a pre-release like
+	// 1.2.3-beta.0 suggests the final releases rather than limiting the suggestions to other beta releases.
 	filter := i.Current.version.Prerelease()
+	currentIsPrerelease := versions.IsPrerelease(i.Current.version)
 	for _, tag := range i.semverTags {
-		if tag.Prerelease() != filter && preReleaseRegex(preReleaseIgnore, tag.Prerelease()) {
-			continue
+		if versions.IsPrerelease(tag) {
+			if !i.IncludePrereleases {
+				continue
+			}
+		} else if tag.Prerelease() != filter {
+			if tag.Prerelease() != "" || !currentIsPrerelease {
+				continue
+			}
 		}
-		if constraint.Check(tag) {
+		if tag.GreaterThan(i.Current.version) {
 			if tag.Major() > i.Current.version.Major()+10 {
 				continue
 			}
@@ -323,12 +330,8 @@ func (i *Image) populateNewest() error {
 		}
 	}
 	if i.Current.version.Major() > 0 {
-		newerMinorConstraint, err := version.NewConstraint(fmt.Sprintf("%d.x.x", i.Current.version.Major()))
-		if err != nil {
-			return errors.Wrap(err, "failed to create minor version constraint")
-		}
 		for _, tag := range newerTags {
-			if newerMinorConstraint.Check(tag) {
+			if tag.Major() == i.Current.version.Major() {
 				i.NewestMinor = &Tag{
 					version: tag,
 					Value:   tag.String(),
@@ -337,12 +340,8 @@ func (i *Image) populateNewest() error {
 			}
 		}
 	}
-	newerPatchConstraint, err := version.NewConstraint(fmt.Sprintf("%d.%d.x", i.Current.version.Major(), i.Current.version.Minor()))
-	if err != nil {
-		return errors.Wrap(err, "failed to create patch version constraint")
-	}
 	for _, tag := range newerTags {
-		if newerPatchConstraint.Check(tag) {
+		if tag.Major() == i.Current.version.Major() && tag.Minor() == i.Current.version.Minor() {
 			i.NewestPatch = &Tag{
 				version: tag,
 				Value:   tag.String(),
@@ -365,20 +364,6 @@ func parseTagString(versionString string) (*version.Version, string, bool) {
 	return strictV, versionString, true
 }
 
-func preReleaseRegex(strings []string, prerelease string) bool {
-	for _, str := range strings {
-		reg, err := regexp.Compile(fmt.Sprintf(`^%s.*`, str))
-		if err != nil {
-			klog.Errorf("Failed to compile regex for %s", str)
-			continue
-		}
-		if reg.MatchString(prerelease) {
-			return true
-		}
-	}
-	return false
-}
-
 // wrapGetAllTopControllersWithPods wraps a call to
 // controller-utils.GetAllTopControllersWithPods(), using members from this
 // Client type to instantiate the controller-utils Client.
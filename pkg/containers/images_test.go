@@ -350,30 +350,63 @@ func TestParseTagString(t *testing.T) {
 	}
 }
 
-func TestPreReleaseRegex(t *testing.T) {
+func TestPopulateNewestPrereleases(t *testing.T) {
 	tests := []struct {
-		name             string
-		preReleaseIgnore []string
-		inputPreRelease  string
-		want             bool
+		name               string
+		current            string
+		includePrereleases bool
+		newestTag          string
+		newestPatch        string
 	}{
 		{
-			name:             "TestPreReleaseRegex",
-			preReleaseIgnore: []string{"alpha", "beta", "rc"},
-			inputPreRelease:  "alpha",
-			want:             bool(true),
+			name:        "prereleases ignored",
+			current:     "1.0.0",
+			newestTag:   "1.1.0",
+			newestPatch: "1.0.1",
 		},
 		{
-			name:             "TestPreReleaseRegexFalse",
-			preReleaseIgnore: []string{"foobar", "alphax"},
-			inputPreRelease:  "alpha",
-			want:             bool(false),
+			name:               "prereleases included",
+			current:            "1.0.0",
+			includePrereleases: true,
+			newestTag:          "2.0.0-rc.1",
+			newestPatch:        "1.0.1",
+		},
+		{
+			name:        "prerelease upgraded to release",
+			current:     "1.0.0-rc.1",
+			newestTag:   "1.1.0",
+			newestPatch: "1.0.1",
+		},
+		{
+			name:        "variant kept",
+			current:     "1.0.0-alpine",
+			newestTag:   "1.1.0-alpine",
+			newestPatch: "1.0.1-alpine",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := preReleaseRegex(tt.preReleaseIgnore, tt.inputPreRelease); got != tt.want {
-				t.Errorf("preReleaseRegex() = %v, want %v", got, tt.want)
+			image := &Image{
+				Name: "test-image",
+				Current: &Tag{
+					version: version.MustParse(tt.current),
+					Value:   tt.current,
+				},
+				IncludePrereleases: tt.includePrereleases,
+				allTags: []string{
+					"1.0.0", "1.0.1", "1.1.0", "2.0.0-rc.1",
+					"1.0.0-alpine", "1.0.1-alpine", "1.1.0-alpine",
+				},
+			}
+			image.parseTags()
+			if err := image.populateNewest(); err != nil {
+				t.Fatalf("populateNewest() error = %v", err)
+			}
+			if image.Newest.Value != tt.newestTag {
+				t.Errorf("populateNewest() Newest.Value got = %v, want %v", image.Newest.Value, tt.newestTag)
+			}
+			if image.NewestPatch.Value != tt.newestPatch {
+				t.Errorf("populateNewest() NewestPatch.Value got = %v, want %v", image.NewestPatch.Value, tt.newestPatch)
 			}
 		})
 	}
@@ -37,6 +37,8 @@ type ArtifactHubPackageClient struct {
 	URL       *url.URL
 	Client    *http.Client
 	UserAgent string
+	// Hub is the hub the client queries
+	Hub HubConfig
 }
 
 // ArtifactHubPackageRepo is a simple struct to show a relationship between a helm package name and its repository.
@@ -111,11 +113,17 @@ type ArtifactHubHelmPackage struct {
 	Maintainers       []Maintainer          `json:"maintainers"`
 	Links             []Link                `json:"links"`
 	Stars             int                   `json:"stars"`
+	// Hub is the name of the hub the package was listed by
+	Hub string `json:"-"`
+	// SecurityReportSummary is only returned for a single package version, not by the package list
+	SecurityReportSummary *ArtifactHubSecurityReportSummary `json:"security_report_summary"`
 }
 
 // AvailableVersion is a sub struct of ArtifactHubHelmPackage and provides a version that is available for a given helm chart.
 type AvailableVersion struct {
-	Version string `json:"version"`
+	Version     string `json:"version"`
+	AppVersion  string `json:"app_version,omitempty"`
+	KubeVersion string `json:"kube_version,omitempty"`
 }
 
 // Maintainer is a child struct of ArtifactHubHelmPackage and provides information about maintainers of a helm chart.
@@ -149,17 +157,26 @@ type Link struct {
 
 // NewArtifactHubPackageClient returns a new client for the unauthenticated paths of the ArtifactHub API.
 func NewArtifactHubPackageClient(version string) (*ArtifactHubPackageClient, error) {
-	apiRoot := artifactHubAPIRoot
+	return NewArtifactHubPackageClientForHub(version, DefaultHub())
+}
+
+// NewArtifactHubPackageClientForHub returns a new client for the API of ArtifactHub or a self-hosted ArtifactHub instance.
+func NewArtifactHubPackageClientForHub(version string, hub HubConfig) (*ArtifactHubPackageClient, error) {
+	apiRoot := hub.rootURL()
 	u, err := url.ParseRequestURI(apiRoot)
 	if err != nil {
 		return nil, err
 	}
-	client := new(http.Client)
+	client, err := hub.httpClient()
+	if err != nil {
+		return nil, err
+	}
 	return &ArtifactHubPackageClient{
 		APIRoot:   apiRoot,
 		URL:       u,
 		Client:    client,
 		UserAgent: fmt.Sprintf("Fairwinds-Nova/%s ", version),
+		Hub:       hub,
 	}, nil
 }
 
@@ -378,25 +395,18 @@ func (ac *ArtifactHubPackageClient) get(path string, urlValues url.Values) (*htt
 	r.URL.RawQuery = q.Encode()
 	r.Header.Add("accept", "application/json")
 	r.Header.Set("User-Agent", ac.UserAgent)
-	var response *http.Response
-	for attempt := 1; attempt <= 5; attempt++ {
-		resp, innerErr := ac.Client.Do(r)
-		if innerErr != nil {
-			response = nil
-			err = innerErr
-			klog.V(3).Infof("attempt %d failed to GET %s: %v", attempt, urlString, err)
-			continue
-		}
-		if resp.StatusCode != http.StatusOK {
-			response = resp
-			klog.V(3).Infof("attempt %d failed to GET %s with status code: %v", attempt, urlString, resp.StatusCode)
-			err = fmt.Errorf("error code: %d", resp.StatusCode)
-			continue
-		}
-		response = resp
-		break
+	if err := ac.Hub.authorize(r); err != nil {
+		return nil, err
+	}
+	resp, err := ac.Client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		klog.V(3).Infof("failed to GET %s with status code: %v", urlString, resp.StatusCode)
+		return resp, fmt.Errorf("error code: %d", resp.StatusCode)
 	}
-	return response, err
+	return resp, nil
 }
 
 func removeDuplicateString(strSlice []string) []string {
@@ -20,12 +20,14 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"time"
 
 	"k8s.io/klog/v2"
 )
 
 const (
-	artifactHubCachedAPIRoot         = "https://artifacthub.io/api/v1/nova"
+	artifactHubCachedAPIPath         = "/api/v1/nova"
+	artifactHubCachedAPIRoot         = artifactHubAPIRoot + artifactHubCachedAPIPath
 	maxArtifactHubCachedRequestLimit = 60
 	artifactHubCachedHelmKind        = "0"
 )
@@ -44,6 +46,18 @@ type ArtifactHubCachedPackageClient struct {
 	URL       *url.URL
 	Client    *http.Client
 	UserAgent string
+	// CacheFile is a package list to read instead of calling the API. It defaults to $ARTIFACT_HUB_CACHE_FILE.
+	CacheFile string
+	// Cache stores the package list between runs. The API is called every time if it is nil.
+	Cache *HTTPCache
+	// Bundle is an offline snapshot created by `nova cache pull`. Its package list is used instead of calling the API.
+	Bundle *Bundle
+	// FetchedAt is when the package list returned by List was downloaded or last revalidated
+	FetchedAt time.Time
+	// Stale is true if List returned an expired cached package list because the API could not be reached
+	Stale bool
+	// Hub is the hub the client queries
+	Hub HubConfig
 }
 
 // ArtifactHubCachedPackagesList contains the output from the AH cached API
@@ -62,6 +76,8 @@ type ArtifactHubCachedPackage struct {
 	Maintainers   []Maintainer                   `json:"maintainers"`
 	Deprecated    bool                           `json:"deprecated"`
 	Stars         int                            `json:"stars"`
+	// Hub is the name of the hub the package was listed by. It is only set in bundles that merge the lists of several hubs.
+	Hub string `json:"hub,omitempty"`
 }
 
 // ArtifactHubCachedRepository is a sub-struct of the Package struct, and represents the repository containing the package.
@@ -82,41 +98,86 @@ type ArtifactHubCachedVersionInfo struct {
 
 // NewArtifactHubCachedPackageClient returns a new client for the unauthenticated paths of the ArtifactHubCached API.
 func NewArtifactHubCachedPackageClient(version string) (*ArtifactHubCachedPackageClient, error) {
-	apiRoot := artifactHubCachedAPIRoot
+	return NewArtifactHubCachedPackageClientForHub(version, DefaultHub())
+}
+
+// NewArtifactHubCachedPackageClientForHub returns a new client for the package list of ArtifactHub or a self-hosted
+// ArtifactHub instance. $ARTIFACT_HUB_CACHE_FILE is only used for the public ArtifactHub.
+func NewArtifactHubCachedPackageClientForHub(version string, hub HubConfig) (*ArtifactHubCachedPackageClient, error) {
+	apiRoot := hub.rootURL() + artifactHubCachedAPIPath
 	u, err := url.ParseRequestURI(apiRoot)
 	if err != nil {
 		return nil, err
 	}
-	client := new(http.Client)
-	return &ArtifactHubCachedPackageClient{
+	client, err := hub.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	ac := &ArtifactHubCachedPackageClient{
 		APIRoot:   apiRoot,
 		URL:       u,
 		Client:    client,
 		UserAgent: fmt.Sprintf("Fairwinds-Nova/%s ", version),
-	}, nil
+		Hub:       hub,
+	}
+	if apiRoot == artifactHubCachedAPIRoot {
+		ac.CacheFile = cacheFile
+	}
+	return ac, nil
 }
 
 // List returns all packages from ArtifactHub
 func (ac *ArtifactHubCachedPackageClient) List() ([]ArtifactHubHelmPackage, error) {
-	list := ArtifactHubCachedPackagesList{}
-	if cacheFile == "" {
-		resp, err := ac.get()
-		if err != nil {
-			return nil, err
-		}
-		err = json.NewDecoder(resp.Body).Decode(&list)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		cache, err := os.ReadFile(cacheFile)
-		if err != nil {
-			return nil, err
+	data, err := ac.FetchPackageList()
+	if err != nil {
+		return nil, err
+	}
+	packages, err := ParsePackageList(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range packages {
+		if packages[i].Hub == "" {
+			packages[i].Hub = ac.Hub.Name
 		}
-		err = json.Unmarshal(cache, &list)
+	}
+	return packages, nil
+}
+
+// FetchPackageList returns the raw package list, from the bundle or cache file if one is set, otherwise from the API
+func (ac *ArtifactHubCachedPackageClient) FetchPackageList() ([]byte, error) {
+	switch {
+	case ac.Bundle != nil:
+		ac.FetchedAt = ac.Bundle.Manifest.ArtifactHubFetchedAt
+		return ac.Bundle.Packages, nil
+	case ac.CacheFile != "":
+		info, err := os.Stat(ac.CacheFile)
 		if err != nil {
 			return nil, err
 		}
+		ac.FetchedAt = info.ModTime()
+		return os.ReadFile(ac.CacheFile)
+	}
+	request, err := ac.newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ac.Cache.Get(ac.Client, request)
+	if err != nil {
+		klog.V(3).Infof("failed to GET %s: %v", request.URL, err)
+		return nil, err
+	}
+	ac.FetchedAt = resp.FetchedAt
+	ac.Stale = resp.Stale
+	return resp.Body, nil
+}
+
+// ParsePackageList converts the raw package list returned by the API into packages
+func ParsePackageList(data []byte) ([]ArtifactHubHelmPackage, error) {
+	list := ArtifactHubCachedPackagesList{}
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, err
 	}
 	packages := make([]ArtifactHubHelmPackage, len(list))
 	for idx, cachedPackage := range list {
@@ -128,6 +189,7 @@ func (ac *ArtifactHubCachedPackageClient) List() ([]ArtifactHubHelmPackage, erro
 			Links:       cachedPackage.Links,
 			Official:    cachedPackage.Official,
 			Stars:       cachedPackage.Stars,
+			Hub:         cachedPackage.Hub,
 			Repository: ArtifactHubRepository{
 				Name:              cachedPackage.Repository.Name,
 				URL:               cachedPackage.Repository.URL,
@@ -143,31 +205,27 @@ func (ac *ArtifactHubCachedPackageClient) List() ([]ArtifactHubHelmPackage, erro
 				packages[idx].KubeVersion = version.KubeVersion
 				packages[idx].Deprecated = version.Deprecated
 			}
-			packages[idx].AvailableVersions = append(packages[idx].AvailableVersions, AvailableVersion{Version: version.Version})
+			packages[idx].AvailableVersions = append(packages[idx].AvailableVersions, AvailableVersion{
+				Version:     version.Version,
+				AppVersion:  version.AppVersion,
+				KubeVersion: version.KubeVersion,
+			})
 		}
 	}
 	return packages, nil
 }
 
-// get is the basic getter for the artifacthub cached package client
-func (ac *ArtifactHubCachedPackageClient) get() (*http.Response, error) {
+// newRequest builds the request for the full package list
+func (ac *ArtifactHubCachedPackageClient) newRequest() (*http.Request, error) {
 	requestURL := *ac.URL
-	urlString := requestURL.String()
-	r, err := http.NewRequest("GET", urlString, nil)
+	r, err := http.NewRequest("GET", requestURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
-	q := r.URL.Query()
-	r.URL.RawQuery = q.Encode()
 	r.Header.Add("accept", "application/json")
 	r.Header.Set("User-Agent", ac.UserAgent)
-	resp, err := ac.Client.Do(r)
-	if err != nil {
+	if err := ac.Hub.authorize(r); err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		klog.V(3).Infof("failed to GET %s with status code: %v", urlString, resp.StatusCode)
-		err = fmt.Errorf("error code: %d", resp.StatusCode)
-	}
-	return resp, err
+	return r, nil
 }
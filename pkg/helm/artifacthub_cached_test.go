@@ -15,7 +15,11 @@
 package helm
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -56,3 +60,43 @@ func Test_ingestSample(t *testing.T) {
 	assert.Equal(t, ">= 1.23.0-0", toCheckWithKubeVersion.KubeVersion)
 	assert.Len(t, toCheckWithKubeVersion.AvailableVersions, 2)
 }
+
+func TestArtifactHubCachedPackageClient_ListCached(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"packages"`)
+		_, _ = w.Write([]byte(`[{"name":"redis","latest_version":"18.0.0","versions":[{"pkg":"18.0.0","app":"7.2.0"}]}]`))
+	}))
+	defer server.Close()
+
+	client, err := NewArtifactHubCachedPackageClient("")
+	assert.NoError(t, err)
+	client.URL, err = url.Parse(server.URL)
+	assert.NoError(t, err)
+	client.Client = server.Client()
+	client.CacheFile = ""
+	client.Cache = &HTTPCache{Dir: t.TempDir(), TTL: time.Hour, AllowStale: true}
+
+	packages, err := client.List()
+	assert.NoError(t, err)
+	if assert.Len(t, packages, 1) {
+		assert.Equal(t, "redis", packages[0].Name)
+		assert.Equal(t, "7.2.0", packages[0].AppVersion)
+	}
+	fetchedAt := client.FetchedAt
+	assert.False(t, fetchedAt.IsZero())
+
+	packages, err = client.List()
+	assert.NoError(t, err)
+	assert.Len(t, packages, 1)
+	assert.Equal(t, 1, requests)
+	assert.True(t, fetchedAt.Equal(client.FetchedAt))
+
+	server.Close()
+	client.Cache.TTL = 0
+	packages, err = client.List()
+	assert.NoError(t, err)
+	assert.Len(t, packages, 1)
+	assert.True(t, client.Stale)
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/klog/v2"
+)
+
+const (
+	bundleManifestFile = "manifest.json"
+	bundlePackagesFile = "artifacthub.json"
+	// maxBundleFileSize protects against decompressing unreasonably large files from a bundle
+	maxBundleFileSize = 1 << 30
+)
+
+// Bundle is an offline snapshot of the ArtifactHub package list and chart repository indexes,
+// stored as a tar.gz file so that nova can be run without network access.
+type Bundle struct {
+	Manifest BundleManifest
+	// Packages is the raw ArtifactHub package list
+	Packages []byte
+	// Indexes are the raw index.yaml files of the chart repositories, keyed by repository URL
+	Indexes map[string][]byte
+}
+
+// BundleManifest describes the contents of a bundle
+type BundleManifest struct {
+	CreatedAt            time.Time          `json:"createdAt"`
+	NovaVersion          string             `json:"novaVersion"`
+	ArtifactHubFetchedAt time.Time          `json:"artifactHubFetchedAt,omitzero"`
+	PackageCount         int                `json:"packageCount"`
+	Repositories         []BundleRepository `json:"repositories,omitempty"`
+}
+
+// BundleRepository is a chart repository index stored in a bundle
+type BundleRepository struct {
+	URL       string    `json:"url"`
+	File      string    `json:"file"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// NewBundle returns an empty bundle created now
+func NewBundle(novaVersion string) *Bundle {
+	return &Bundle{
+		Manifest: BundleManifest{
+			CreatedAt:   time.Now().UTC(),
+			NovaVersion: novaVersion,
+		},
+		Indexes: map[string][]byte{},
+	}
+}
+
+// SetPackages stores the raw ArtifactHub package list in the bundle
+func (b *Bundle) SetPackages(data []byte, fetchedAt time.Time) error {
+	list := ArtifactHubCachedPackagesList{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid artifacthub package list: %v", err)
+	}
+	b.Packages = data
+	b.Manifest.ArtifactHubFetchedAt = fetchedAt.UTC()
+	b.Manifest.PackageCount = len(list)
+	return nil
+}
+
+// AddIndex stores the raw index.yaml of a chart repository in the bundle
+func (b *Bundle) AddIndex(url string, data []byte, fetchedAt time.Time) {
+	url = strings.TrimSuffix(url, "/")
+	if _, ok := b.Indexes[url]; !ok {
+		b.Manifest.Repositories = append(b.Manifest.Repositories, BundleRepository{
+			URL:       url,
+			File:      fmt.Sprintf("repositories/%d-index.yaml", len(b.Manifest.Repositories)),
+			FetchedAt: fetchedAt.UTC(),
+		})
+	}
+	b.Indexes[url] = data
+}
+
+// WriteFile writes the bundle to a tar.gz file
+func (b *Bundle) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, bundleManifestFile, manifest); err != nil {
+		return err
+	}
+	if b.Packages != nil {
+		if err := writeTarFile(tw, bundlePackagesFile, b.Packages); err != nil {
+			return err
+		}
+	}
+	for _, repo := range b.Manifest.Repositories {
+		if err := writeTarFile(tw, repo.File, b.Indexes[repo.URL]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// ReadBundle reads a bundle written by WriteFile
+func ReadBundle(path string) (*Bundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bundle %s: %v", path, err)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read bundle %s: %v", path, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxBundleFileSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s from bundle %s: %v", header.Name, path, err)
+		}
+		if len(data) > maxBundleFileSize {
+			return nil, fmt.Errorf("bundle entry %s exceeds %d bytes", header.Name, maxBundleFileSize)
+		}
+		files[header.Name] = data
+	}
+
+	manifest, ok := files[bundleManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("bundle %s has no %s", path, bundleManifestFile)
+	}
+	b := &Bundle{Indexes: map[string][]byte{}}
+	if err := json.Unmarshal(manifest, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest in bundle %s: %v", path, err)
+	}
+	b.Packages = files[bundlePackagesFile]
+	for _, repo := range b.Manifest.Repositories {
+		data, ok := files[repo.File]
+		if !ok {
+			return nil, fmt.Errorf("bundle %s is missing %s for repository %s", path, repo.File, repo.URL)
+		}
+		b.Indexes[repo.URL] = data
+	}
+	return b, nil
+}
+
+// Repos builds repos from the chart repository indexes in the bundle. The configs of repositories
+// that are not in the bundle are returned so that they can be loaded with NewReposFromConfig.
+func (b *Bundle) Repos(configs []RepoConfig) ([]*Repo, []RepoConfig) {
+	repos := []*Repo{}
+	for _, repo := range b.Manifest.Repositories {
+		charts := &ChartReleases{}
+		if err := yaml.Unmarshal(b.Indexes[repo.URL], charts); err != nil {
+			klog.Errorf("could not parse index of chart repo %s from bundle: %v", repo.URL, err)
+			continue
+		}
+		repos = append(repos, &Repo{
+			URL:    repo.URL,
+			Charts: charts,
+			Config: RepoConfig{URL: repo.URL},
+		})
+	}
+	uncached := []RepoConfig{}
+	for _, config := range configs {
+		if _, ok := b.Indexes[strings.TrimSuffix(config.URL, "/")]; !ok {
+			uncached = append(uncached, config)
+		}
+	}
+	return repos, uncached
+}
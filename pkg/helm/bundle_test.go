@@ -0,0 +1,71 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundle_RoundTrip(t *testing.T) {
+	fetchedAt := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	bundle := NewBundle("v1.0.0")
+	assert.NoError(t, bundle.SetPackages([]byte(`[{"name":"redis","latest_version":"18.0.0"},{"name":"nginx"}]`), fetchedAt))
+	assert.Error(t, bundle.SetPackages([]byte(`not json`), fetchedAt))
+	bundle.AddIndex("https://charts.example.com/", []byte(testIndex), fetchedAt)
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	assert.NoError(t, bundle.WriteFile(path))
+
+	got, err := ReadBundle(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.0.0", got.Manifest.NovaVersion)
+	assert.Equal(t, 2, got.Manifest.PackageCount)
+	assert.Equal(t, fetchedAt, got.Manifest.ArtifactHubFetchedAt)
+	assert.Equal(t, []BundleRepository{
+		{URL: "https://charts.example.com", File: "repositories/0-index.yaml", FetchedAt: fetchedAt},
+	}, got.Manifest.Repositories)
+
+	client, err := NewArtifactHubCachedPackageClient("")
+	assert.NoError(t, err)
+	client.Bundle = got
+	packages, err := client.List()
+	assert.NoError(t, err)
+	assert.Len(t, packages, 2)
+	assert.Equal(t, fetchedAt, client.FetchedAt)
+
+	repos, unbundled := got.Repos([]RepoConfig{
+		{URL: "https://charts.example.com"},
+		{URL: "https://other.example.com"},
+	})
+	if assert.Len(t, repos, 1) {
+		assert.Len(t, repos[0].Charts.Entries["test"], 2)
+	}
+	assert.Equal(t, []RepoConfig{{URL: "https://other.example.com"}}, unbundled)
+}
+
+func TestReadBundle_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	assert.NoError(t, os.WriteFile(path, []byte("not a bundle"), 0600))
+	_, err := ReadBundle(path)
+	assert.Error(t, err)
+
+	_, err = ReadBundle(filepath.Join(t.TempDir(), "missing.tar.gz"))
+	assert.Error(t, err)
+}
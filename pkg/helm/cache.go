@@ -0,0 +1,216 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// HTTPCache stores downloaded files on disk, keyed by URL, and revalidates them with conditional requests
+// using the ETag and Last-Modified headers of the original response.
+type HTTPCache struct {
+	Dir string
+	// TTL is how long a cached file is used without revalidating it
+	TTL time.Duration
+	// Refresh downloads files again even if they are cached, and updates the cache with the new content
+	Refresh bool
+	// AllowStale returns the cached file, however old, when the server cannot be reached or returns a server error
+	AllowStale bool
+}
+
+// CachedResponse is the body of a response and when it was last downloaded or revalidated
+type CachedResponse struct {
+	Body      []byte
+	FetchedAt time.Time
+	// Stale is true when the server could not be reached and an expired cached file was returned instead
+	Stale bool
+}
+
+// HTTPStatusError is returned when a server responds with an unexpected status code
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("got status code %d from %s", e.StatusCode, e.URL)
+}
+
+type cacheMetadata struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// DefaultCacheDir returns the directory nova caches downloaded files in
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "nova")
+	}
+	return filepath.Join(dir, "nova")
+}
+
+// Get sends the request, using the cached response when it is fresh or the server confirms it has not changed.
+// A nil cache always sends the request.
+func (c *HTTPCache) Get(client *http.Client, request *http.Request) (*CachedResponse, error) {
+	if c == nil {
+		return fetch(client, request)
+	}
+	dataPath, metaPath := c.paths(request.URL.String())
+	cached, meta := c.load(dataPath, metaPath)
+	if cached != nil && !c.Refresh {
+		age := time.Since(meta.FetchedAt)
+		if age < c.TTL {
+			klog.V(8).Infof("using cached %s, fetched %s ago", request.URL, age.Round(time.Second))
+			return &CachedResponse{Body: cached, FetchedAt: meta.FetchedAt}, nil
+		}
+		if meta.ETag != "" {
+			request.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			request.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return c.stale(cached, meta, err)
+	}
+	defer response.Body.Close()
+
+	now := time.Now()
+	switch {
+	case response.StatusCode == http.StatusNotModified && cached != nil:
+		klog.V(8).Infof("cached %s has not been modified", request.URL)
+		meta.FetchedAt = now
+		c.writeMetadata(metaPath, meta)
+		return &CachedResponse{Body: cached, FetchedAt: now}, nil
+	case response.StatusCode >= http.StatusInternalServerError:
+		return c.stale(cached, meta, &HTTPStatusError{URL: request.URL.String(), StatusCode: response.StatusCode})
+	case response.StatusCode != http.StatusOK:
+		return nil, &HTTPStatusError{URL: request.URL.String(), StatusCode: response.StatusCode}
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return c.stale(cached, meta, err)
+	}
+	meta = cacheMetadata{
+		URL:          request.URL.String(),
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+		FetchedAt:    now,
+	}
+	if err := c.store(dataPath, metaPath, body, meta); err != nil {
+		klog.V(3).Infof("could not cache %s: %v", request.URL, err)
+	}
+	return &CachedResponse{Body: body, FetchedAt: now}, nil
+}
+
+// stale returns the cached file instead of err if AllowStale is set and a file is cached
+func (c *HTTPCache) stale(cached []byte, meta cacheMetadata, err error) (*CachedResponse, error) {
+	if !c.AllowStale || cached == nil {
+		return nil, err
+	}
+	klog.Warningf("using cached %s from %s because it could not be downloaded: %v", meta.URL, meta.FetchedAt.Format(time.RFC3339), err)
+	return &CachedResponse{Body: cached, FetchedAt: meta.FetchedAt, Stale: true}, nil
+}
+
+func fetch(client *http.Client, request *http.Request) (*CachedResponse, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{URL: request.URL.String(), StatusCode: response.StatusCode}
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedResponse{Body: body, FetchedAt: time.Now()}, nil
+}
+
+func (c *HTTPCache) paths(url string) (string, string) {
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.Dir, key), filepath.Join(c.Dir, key+".json")
+}
+
+// load returns the cached body and its metadata, or a nil body if nothing usable is cached
+func (c *HTTPCache) load(dataPath, metaPath string) ([]byte, cacheMetadata) {
+	var meta cacheMetadata
+	rawMeta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, meta
+	}
+	if err := json.Unmarshal(rawMeta, &meta); err != nil {
+		klog.V(5).Infof("ignoring invalid cache metadata %s: %v", metaPath, err)
+		return nil, meta
+	}
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, meta
+	}
+	return data, meta
+}
+
+func (c *HTTPCache) store(dataPath, metaPath string, body []byte, meta cacheMetadata) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(dataPath, body); err != nil {
+		return err
+	}
+	return c.writeMetadata(metaPath, meta)
+}
+
+func (c *HTTPCache) writeMetadata(metaPath string, meta cacheMetadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, data)
+}
+
+// writeFileAtomic writes to a temporary file first so that concurrent runs never read a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
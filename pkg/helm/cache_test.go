@@ -0,0 +1,113 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPCache_Get(t *testing.T) {
+	var downloads, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(testIndex))
+	}))
+	defer server.Close()
+
+	get := func(cache *HTTPCache) *CachedResponse {
+		request, err := http.NewRequest("GET", server.URL+"/index.yaml", nil)
+		assert.NoError(t, err)
+		response, err := cache.Get(http.DefaultClient, request)
+		assert.NoError(t, err)
+		return response
+	}
+
+	cache := &HTTPCache{Dir: t.TempDir(), TTL: time.Hour}
+	assert.Equal(t, testIndex, string(get(cache).Body))
+	assert.Equal(t, testIndex, string(get(cache).Body))
+	assert.Equal(t, 1, downloads, "a fresh cached file should not be requested again")
+	assert.Equal(t, 0, notModified)
+
+	cache.TTL = 0
+	assert.Equal(t, testIndex, string(get(cache).Body))
+	assert.Equal(t, 1, downloads)
+	assert.Equal(t, 1, notModified, "an expired cached file should be revalidated")
+
+	cache.Refresh = true
+	assert.Equal(t, testIndex, string(get(cache).Body))
+	assert.Equal(t, 2, downloads, "refresh should download the file again")
+
+	var nilCache *HTTPCache
+	assert.Equal(t, testIndex, string(get(nilCache).Body))
+	assert.Equal(t, 3, downloads)
+}
+
+func TestHTTPCache_GetStatusError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL+"/index.yaml", nil)
+	assert.NoError(t, err)
+	_, err = (&HTTPCache{Dir: t.TempDir()}).Get(http.DefaultClient, request)
+	assert.Equal(t, &HTTPStatusError{URL: server.URL + "/index.yaml", StatusCode: http.StatusNotFound}, err)
+}
+
+func TestHTTPCache_GetStale(t *testing.T) {
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(testIndex))
+	}))
+	defer server.Close()
+
+	get := func(cache *HTTPCache) (*CachedResponse, error) {
+		request, err := http.NewRequest("GET", server.URL+"/index.yaml", nil)
+		assert.NoError(t, err)
+		return cache.Get(http.DefaultClient, request)
+	}
+
+	cache := &HTTPCache{Dir: t.TempDir()}
+	_, err := get(cache)
+	assert.NoError(t, err)
+
+	up = false
+	_, err = get(cache)
+	assert.Error(t, err, "stale files should only be used when allowed")
+
+	cache.AllowStale = true
+	response, err := get(cache)
+	assert.NoError(t, err)
+	assert.True(t, response.Stale)
+	assert.Equal(t, testIndex, string(response.Body))
+
+	server.Close()
+	response, err = get(cache)
+	assert.NoError(t, err, "stale files should be used when the server is unreachable")
+	assert.True(t, response.Stale)
+}
@@ -15,14 +15,14 @@
 package helm
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/fairwindsops/nova/pkg/output"
-	version "github.com/mcuadros/go-version"
+	"github.com/fairwindsops/nova/pkg/versions"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
 
@@ -34,6 +34,10 @@ import (
 type Repo struct {
 	URL    string
 	Charts *ChartReleases
+	Config RepoConfig
+	cache  *HTTPCache
+	// oci is set for OCI registries, whose chart metadata is fetched on demand
+	oci *ociSource
 }
 
 // ChartReleases contains the chart releases of a helm repository
@@ -59,121 +63,194 @@ type ChartRelease struct {
 	Keywords    []string           `json:"keywords"`
 	Icon        string             `json:"icon"`
 	Deprecated  bool               `json:"deprecated"`
+	// ociTag is the tag of a chart version in an OCI registry whose metadata has not been fetched yet
+	ociTag string
 }
 
 // NewRepos returns data about a helm chart repository, given its url
 func NewRepos(urls []string) []*Repo {
+	configs := make([]RepoConfig, len(urls))
+	for i, url := range urls {
+		configs[i] = RepoConfig{URL: url}
+	}
+	repos, _ := NewReposFromConfig(configs, nil)
+	return repos
+}
+
+// RepoLoadError is returned when a chart repository index could not be loaded
+type RepoLoadError struct {
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *RepoLoadError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("could not load chart repo %s: status code %d", e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("could not load chart repo %s: %v", e.URL, e.Err)
+}
+
+func (e *RepoLoadError) Unwrap() error {
+	return e.Err
+}
+
+// NewReposFromConfig returns data about helm chart repositories, using the credentials and TLS settings of each repository.
+// Index files are stored in and revalidated against the cache, unless it is nil. Repositories that could not be
+// loaded are returned as errors.
+func NewReposFromConfig(configs []RepoConfig, cache *HTTPCache) ([]*Repo, []output.RepoError) {
 	var repos []*Repo
+	var repoErrors []output.RepoError
 
 	var mutex = &sync.Mutex{}
 	var wg sync.WaitGroup
-	wg.Add(len(urls))
+	wg.Add(len(configs))
 
-	klog.V(5).Infof("loading %d chart repositories", len(urls))
+	klog.V(5).Infof("loading %d chart repositories", len(configs))
 
-	for _, url := range urls {
-		klog.V(8).Infof("loading chart repository: %s", url)
-		go func(address string) {
+	for _, config := range configs {
+		klog.V(8).Infof("loading chart repository: %s", config.URL)
+		go func(config RepoConfig) {
 			defer wg.Done()
 			repo := &Repo{
-				URL:    address,
+				URL:    config.URL,
 				Charts: &ChartReleases{},
+				Config: config,
+				cache:  cache,
 			}
 			err := repo.loadReleases()
+			mutex.Lock()
+			defer mutex.Unlock()
 			if err != nil {
-				klog.V(5).Infof("Could not load chart repo %s: %s", address, err)
+				klog.V(3).Infof("Could not load chart repo %s: %s", config.URL, err)
+				repoErrors = append(repoErrors, newRepoError(config.URL, err))
 			} else {
-				mutex.Lock()
 				repos = append(repos, repo)
-				mutex.Unlock()
 			}
-		}(url)
+		}(config)
 	}
 
 	wg.Wait()
-	return repos
+	return repos, repoErrors
+}
+
+func newRepoError(url string, err error) output.RepoError {
+	repoError := output.RepoError{URL: url, Error: err.Error()}
+	var loadErr *RepoLoadError
+	if errors.As(err, &loadErr) {
+		repoError.StatusCode = loadErr.StatusCode
+		if loadErr.Err != nil {
+			repoError.Error = loadErr.Err.Error()
+		} else {
+			repoError.Error = http.StatusText(loadErr.StatusCode)
+		}
+	}
+	return repoError
 }
 
 func (r *Repo) loadReleases() error {
-	response, err := http.Get(fmt.Sprintf("%s/index.yaml", r.URL))
+	if IsOCIRepo(r.URL) {
+		err := r.loadOCIReleases()
+		if err != nil {
+			return &RepoLoadError{URL: r.URL, Err: err}
+		}
+		return nil
+	}
+	response, err := FetchRepoIndex(r.Config, r.cache)
 	if err != nil {
 		return err
 	}
 
-	data, err := io.ReadAll(response.Body)
+	err = yaml.Unmarshal(response.Body, r.Charts)
 	if err != nil {
-		return err
+		return &RepoLoadError{URL: r.URL, Err: fmt.Errorf("could not parse index.yaml: %v", err)}
 	}
+	return nil
+}
 
-	err = yaml.Unmarshal(data, r.Charts)
+// FetchRepoIndex downloads the index.yaml of an http chart repository, using the cache if it is not nil
+func FetchRepoIndex(config RepoConfig, cache *HTTPCache) (*CachedResponse, error) {
+	client, err := config.httpClient()
 	if err != nil {
-		return err
+		return nil, &RepoLoadError{URL: config.URL, Err: err}
 	}
-	return nil
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/index.yaml", config.URL), nil)
+	if err != nil {
+		return nil, &RepoLoadError{URL: config.URL, Err: err}
+	}
+	err = config.authorize(request)
+	if err != nil {
+		return nil, &RepoLoadError{URL: config.URL, Err: err}
+	}
+	response, err := cache.Get(client, request)
+	if err != nil {
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) {
+			return nil, &RepoLoadError{URL: config.URL, StatusCode: statusErr.StatusCode}
+		}
+		return nil, &RepoLoadError{URL: config.URL, Err: err}
+	}
+	return response, nil
 }
 
 // NewestVersion returns the newest chart release for the provided release name
-func (r *Repo) NewestVersion(releaseName string) *ChartRelease {
-	for name, entries := range r.Charts.Entries {
-		if name == releaseName {
-			var newest ChartRelease
-			for _, release := range entries {
-				if IsValidRelease(release.Version) {
-					if newest.Version == "" {
-						newest = release
-					}
-
-					foundNewer := version.Compare(release.Version, newest.Version, ">")
-					if foundNewer {
-						newest = release
-					}
-				}
-			}
-			return &newest
+func (r *Repo) NewestVersion(releaseName string, includePrereleases bool) *ChartRelease {
+	entries, ok := r.Charts.Entries[releaseName]
+	if !ok {
+		return nil
+	}
+	newest := -1
+	for i, release := range entries {
+		if versions.IsValid(release.Version, includePrereleases) && (newest < 0 || versions.Newer(release.Version, entries[newest].Version)) {
+			newest = i
 		}
 	}
-	return nil
+	if newest < 0 {
+		return &ChartRelease{}
+	}
+	release := r.chartRelease(releaseName, newest)
+	return &release
 }
 
 // NewestChartVersion returns the newest chart release for the provided release name and version
-func (r *Repo) NewestChartVersion(currentChart *chart.Metadata) *ChartRelease {
-	for name, entries := range r.Charts.Entries {
-		if name == currentChart.Name {
-			var newest ChartRelease
-			repoHasCurrentVersion := false
-			for _, release := range entries {
-				if IsValidRelease(release.Version) {
-					if release.Version == currentChart.Version {
-						repoHasCurrentVersion = checkChartsSimilarity(currentChart, &release)
-					}
-
-					foundNewer := version.Compare(release.Version, newest.Version, ">")
-					if foundNewer {
-						newest = release
-					}
-				}
-			}
-			if repoHasCurrentVersion {
-				return &newest
-			}
-
+func (r *Repo) NewestChartVersion(currentChart *chart.Metadata, includePrereleases bool) *ChartRelease {
+	entries, ok := r.Charts.Entries[currentChart.Name]
+	if !ok {
+		return nil
+	}
+	repoHasCurrentVersion := false
+	newest := -1
+	for i, release := range entries {
+		if release.Version == currentChart.Version {
+			current := r.chartRelease(currentChart.Name, i)
+			repoHasCurrentVersion = checkChartsSimilarity(currentChart, &current)
+		}
+		if versions.IsValid(release.Version, includePrereleases) && (newest < 0 || versions.Newer(release.Version, entries[newest].Version)) {
+			newest = i
 		}
 	}
-	return nil
+	if !repoHasCurrentVersion {
+		return nil
+	}
+	if newest < 0 {
+		return &ChartRelease{}
+	}
+	release := r.chartRelease(currentChart.Name, newest)
+	return &release
 }
 
 // TryToFindNewestReleaseByChart will return the newest chart release given a collection of repos
-func TryToFindNewestReleaseByChart(chart *release.Release, repos []*Repo) *ChartRelease {
+func TryToFindNewestReleaseByChart(chart *release.Release, repos []*Repo, includePrereleases bool) *ChartRelease {
 	var newestRelease *ChartRelease
 	for _, repo := range repos {
-		newestInRepo := repo.NewestChartVersion(chart.Chart.Metadata)
+		newestInRepo := repo.NewestChartVersion(chart.Chart.Metadata, includePrereleases)
 		if newestInRepo == nil {
 			continue
 		}
 		if newestRelease == nil {
 			newestRelease = newestInRepo
 		} else {
-			if version.Compare(newestInRepo.Version, newestRelease.Version, ">") {
+			if versions.Newer(newestInRepo.Version, newestRelease.Version) {
 				newestRelease = newestInRepo
 			}
 		}
@@ -188,7 +265,7 @@ func (h *Helm) GetHelmReleasesVersion(helmRepos []*Repo, helmReleases []*release
 	klog.V(5).Infof("Got %d installed releases in the cluster", len(helmReleases))
 	for _, chart := range helmReleases {
 		validRepos := IsRepoIncluded(chart.Chart.Metadata.Name, helmRepos)
-		newest := TryToFindNewestReleaseByChart(chart, validRepos)
+		newest := TryToFindNewestReleaseByChart(chart, validRepos, h.IncludePrereleases)
 		if newest != nil {
 			rls := output.ReleaseOutput{
 				ReleaseName: chart.Name,
@@ -210,24 +287,19 @@ func (h *Helm) GetHelmReleasesVersion(helmRepos []*Repo, helmReleases []*release
 				HelmVersion: "v3",
 				Deprecated:  chart.Chart.Metadata.Deprecated,
 			}
-			h.overrideDesiredVersion(&rls)
-			rls.IsOld = version.Compare(rls.Latest.Version, chart.Chart.Metadata.Version, ">")
+			if rls.Deprecated {
+				rls.DeprecationReason, rls.Replacement = DeprecationInfo(chart.Chart.Metadata, newest.Description, nil, nil)
+			}
+			rls.SetReleaseInfo(chart.Info)
+			SetUpgradePaths(&rls, chartVersionsFromRepos(chart, validRepos), h.IncludePrereleases)
+			rls.IsOld = versions.Newer(rls.Latest.Version, chart.Chart.Metadata.Version)
+			h.OverrideDesiredVersion(&rls)
 			outputObjects = append(outputObjects, rls)
 		}
 	}
 	return outputObjects
 }
 
-func (h *Helm) overrideDesiredVersion(rls *output.ReleaseOutput) {
-	for _, override := range h.DesiredVersions {
-		if rls.ChartName == override.Name {
-			klog.V(3).Infof("using override: %s=%s", rls.ChartName, override.Version)
-			rls.Latest = output.VersionInfo{Version: override.Version}
-			rls.Overridden = true
-		}
-	}
-}
-
 func checkChartsSimilarity(currentChartMeta *chart.Metadata, chartFromRepo *ChartRelease) bool {
 
 	if currentChartMeta.Home != chartFromRepo.Home {
@@ -257,14 +329,14 @@ func checkChartsSimilarity(currentChartMeta *chart.Metadata, chartFromRepo *Char
 }
 
 // GetNewestReleaseByName will return the newest chart release given a collection of repos
-func GetNewestReleaseByName(name string, repos []*Repo) *ChartRelease {
+func GetNewestReleaseByName(name string, repos []*Repo, includePrereleases bool) *ChartRelease {
 	newestRelease := &ChartRelease{}
 	for _, repo := range repos {
-		newestInRepo := repo.NewestVersion(name)
+		newestInRepo := repo.NewestVersion(name, includePrereleases)
 		if newestRelease == nil {
 			newestRelease = newestInRepo
 		} else {
-			if version.Compare(newestInRepo.Version, newestRelease.Version, ">") {
+			if versions.Newer(newestInRepo.Version, newestRelease.Version) {
 				newestRelease = newestInRepo
 			}
 		}
@@ -275,13 +347,10 @@ func GetNewestReleaseByName(name string, repos []*Repo) *ChartRelease {
 // GetChartInfo returns info about a chart with the version specified
 func GetChartInfo(name string, version string, repos []*Repo) *ChartRelease {
 	for _, repo := range repos {
-		for key, chart := range repo.Charts.Entries {
-			if key == name {
-				for _, release := range chart {
-					if release.Version == version {
-						return &release
-					}
-				}
+		for i, release := range repo.Charts.Entries[name] {
+			if release.Version == version {
+				release = repo.chartRelease(name, i)
+				return &release
 			}
 		}
 	}
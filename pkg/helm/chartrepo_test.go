@@ -0,0 +1,55 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReposFromConfig_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good/index.yaml":
+			_, _ = w.Write([]byte(testIndex))
+		case "/broken/index.yaml":
+			_, _ = w.Write([]byte("entries: [this is not: an index"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	repos, repoErrors := NewReposFromConfig([]RepoConfig{
+		{URL: server.URL + "/good"},
+		{URL: server.URL + "/typo"},
+		{URL: server.URL + "/broken"},
+	}, nil)
+	assert.Len(t, repos, 1)
+	if assert.Len(t, repoErrors, 2) {
+		byURL := map[string]int{}
+		for i, e := range repoErrors {
+			byURL[e.URL] = i
+		}
+		typo := repoErrors[byURL[server.URL+"/typo"]]
+		assert.Equal(t, http.StatusNotFound, typo.StatusCode)
+		broken := repoErrors[byURL[server.URL+"/broken"]]
+		assert.Equal(t, 0, broken.StatusCode)
+		assert.Contains(t, broken.Error, "could not parse index.yaml")
+	}
+}
@@ -17,26 +17,119 @@ package helm
 import (
 	"fmt"
 	"slices"
+	"strings"
 
+	semver "github.com/Masterminds/semver/v3"
 	"github.com/fairwindsops/nova/pkg/kube"
 	"github.com/fairwindsops/nova/pkg/output"
-	version "github.com/mcuadros/go-version"
+	"github.com/fairwindsops/nova/pkg/versions"
 	"helm.sh/helm/v3/pkg/release"
 	helmstorage "helm.sh/helm/v3/pkg/storage"
 	helmdriver "helm.sh/helm/v3/pkg/storage/driver"
 	"k8s.io/klog/v2"
 )
 
+const (
+	// DriverSecret reads releases from Kubernetes secrets, the helm default
+	DriverSecret = "secret"
+	// DriverConfigMap reads releases from Kubernetes configmaps
+	DriverConfigMap = "configmap"
+	// DriverSQL reads releases from a SQL database
+	DriverSQL = "sql"
+	// DriverAuto reads releases from both secrets and configmaps
+	DriverAuto = "auto"
+)
+
 // Helm contains a helm version and kubernetes client interface
 type Helm struct {
 	Kube            *kube.Connection
 	DesiredVersions []DesiredVersion
+	// Driver is the helm storage driver used to find releases. Defaults to DriverSecret when empty.
+	Driver string
+	// SQLConnectionString is the connection string used by DriverSQL
+	SQLConnectionString string
+	// AllStatuses includes the latest revision of releases in any status (failed, pending-upgrade, etc), not just deployed ones
+	AllStatuses bool
+	// Matcher matches releases to artifacthub packages. The default matcher is used when nil.
+	Matcher *ArtifactHubMatcher
+	// IncludePrereleases considers pre-release chart versions, like 1.0.0-rc.1, when looking for the newest version
+	IncludePrereleases bool
 }
 
-// DesiredVersion is a specific desired version that overrides the latest from the repository
+// matcher returns the artifacthub matcher of the helm client, or the default one
+func (h *Helm) matcher() *ArtifactHubMatcher {
+	if h.Matcher == nil {
+		return &ArtifactHubMatcher{IncludePrereleases: h.IncludePrereleases}
+	}
+	return h.Matcher
+}
+
+// DesiredVersion is a desired version that overrides the latest from the repository. Version is either an exact
+// version, which replaces the latest version, or a semver constraint like ~1.4 or ^3.0.0, which limits the latest
+// version to the newest available version that satisfies it.
 type DesiredVersion struct {
-	Name    string
-	Version string
+	// Name is the chart name the desired version applies to. Empty matches any chart.
+	Name string `mapstructure:"chart"`
+	// Namespace limits the desired version to releases in a namespace
+	Namespace string `mapstructure:"namespace"`
+	// Release limits the desired version to releases with this name
+	Release string `mapstructure:"release"`
+	Version string `mapstructure:"version"`
+}
+
+// Validate checks that a desired version is scoped to a chart or a release and that its version is an exact
+// version or a valid constraint
+func (d DesiredVersion) Validate() error {
+	if d.Name == "" && d.Release == "" {
+		return fmt.Errorf("desired version %q must set a chart or a release", d.Version)
+	}
+	if d.Version == "" {
+		return fmt.Errorf("desired version for %s has no version", d.scope())
+	}
+	if _, err := versions.Parse(d.Version); err == nil {
+		return nil
+	}
+	if _, err := semver.NewConstraint(d.Version); err != nil {
+		return fmt.Errorf("desired version %q for %s is not a version or a constraint: %v", d.Version, d.scope(), err)
+	}
+	return nil
+}
+
+// matches reports whether the desired version applies to a release
+func (d DesiredVersion) matches(rls *output.ReleaseOutput) bool {
+	return (d.Name == "" || d.Name == rls.ChartName) &&
+		(d.Namespace == "" || d.Namespace == rls.Namespace) &&
+		(d.Release == "" || d.Release == rls.ReleaseName)
+}
+
+// specificity ranks desired versions so that one scoped to a release wins over one scoped to a namespace,
+// which wins over one that only names a chart
+func (d DesiredVersion) specificity() int {
+	score := 0
+	if d.Release != "" {
+		score += 4
+	}
+	if d.Namespace != "" {
+		score += 2
+	}
+	if d.Name != "" {
+		score++
+	}
+	return score
+}
+
+func (d DesiredVersion) scope() string {
+	parts := []string{}
+	if d.Namespace != "" {
+		parts = append(parts, "namespace "+d.Namespace)
+	}
+	if d.Release != "" {
+		parts = append(parts, "release "+d.Release)
+	}
+	if d.Name != "" {
+		parts = append(parts, "chart "+d.Name)
+	}
+	return strings.Join(parts, ", ")
 }
 
 // NewHelm returns a basic helm struct with the version of helm requested
@@ -64,28 +157,138 @@ func (h *Helm) GetReleaseOutput(namespace string, releaseIgnoreList []string, ch
 
 // GetHelmReleases returns a list of helm releases from the cluster
 func (h *Helm) GetHelmReleases(namespace string, releaseIgnoreList []string, chartIgnoreList []string) ([]*release.Release, error) {
-	hs := helmdriver.NewSecrets(h.Kube.Client.CoreV1().Secrets(namespace))
-	helmClient := helmstorage.Init(hs)
-	deployed, err := helmClient.ListDeployed()
-
-	filteredDeployed := filterIgnoredReleases(deployed, releaseIgnoreList, chartIgnoreList)
-
+	drivers, err := h.storageDrivers(namespace)
 	if err != nil {
 		return nil, err
 	}
-	return filteredDeployed, nil
+
+	var deployed []*release.Release
+	var errs []string
+	for _, d := range drivers {
+		helmClient := helmstorage.Init(d)
+		var releases []*release.Release
+		var err error
+		if h.AllStatuses {
+			releases, err = helmClient.ListReleases()
+			releases = latestRevisions(releases)
+		} else {
+			releases, err = helmClient.ListDeployed()
+		}
+		if err != nil {
+			klog.V(3).Infof("could not list releases using the %s driver: %v", d.Name(), err)
+			errs = append(errs, fmt.Sprintf("%s: %v", d.Name(), err))
+			continue
+		}
+		klog.V(5).Infof("found %d releases using the %s driver", len(releases), d.Name())
+		deployed = append(deployed, releases...)
+	}
+	if len(errs) == len(drivers) {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return filterIgnoredReleases(deployed, releaseIgnoreList, chartIgnoreList), nil
 }
 
-// OverrideDesiredVersion accepts a list of releases and overrides the version stored in the helm struct where required
+// latestRevisions returns only the most recent revision of each release, skipping releases that have been uninstalled
+func latestRevisions(releases []*release.Release) []*release.Release {
+	type key struct{ name, namespace string }
+	latest := map[key]*release.Release{}
+	order := []key{}
+	for _, r := range releases {
+		k := key{r.Name, r.Namespace}
+		current, ok := latest[k]
+		if !ok {
+			order = append(order, k)
+		}
+		if !ok || r.Version > current.Version {
+			latest[k] = r
+		}
+	}
+	filtered := []*release.Release{}
+	for _, k := range order {
+		r := latest[k]
+		if r.Info != nil && r.Info.Status == release.StatusUninstalled {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
+// storageDrivers returns the helm storage drivers to read releases from, based on the configured Driver.
+// Releases found by more than one driver are deduped later by output.Dedupe
+func (h *Helm) storageDrivers(namespace string) ([]helmdriver.Driver, error) {
+	switch strings.ToLower(h.Driver) {
+	case "", DriverSecret, "secrets":
+		return []helmdriver.Driver{helmdriver.NewSecrets(h.Kube.Client.CoreV1().Secrets(namespace))}, nil
+	case DriverConfigMap, "configmaps":
+		return []helmdriver.Driver{helmdriver.NewConfigMaps(h.Kube.Client.CoreV1().ConfigMaps(namespace))}, nil
+	case DriverSQL:
+		if h.SQLConnectionString == "" {
+			return nil, fmt.Errorf("the %s helm driver requires a connection string", DriverSQL)
+		}
+		// the driver does not expose its connection pool, which is released when nova exits
+		d, err := helmdriver.NewSQL(h.SQLConnectionString, klog.V(8).Infof, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to the helm sql storage: %v", err)
+		}
+		return []helmdriver.Driver{d}, nil
+	case DriverAuto:
+		return []helmdriver.Driver{
+			helmdriver.NewSecrets(h.Kube.Client.CoreV1().Secrets(namespace)),
+			helmdriver.NewConfigMaps(h.Kube.Client.CoreV1().ConfigMaps(namespace)),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown helm driver %q, must be one of %s, %s, %s or %s", h.Driver, DriverSecret, DriverConfigMap, DriverSQL, DriverAuto)
+	}
+}
+
+// OverrideDesiredVersion overrides the latest version of a release with the most specific desired version that
+// applies to it. A constraint sets the latest version to the newest available version that satisfies it, and
+// keeps the newest version overall in NewestVersion.
 func (h *Helm) OverrideDesiredVersion(rls *output.ReleaseOutput) {
-	for _, override := range h.DesiredVersions {
-		if rls.ChartName == override.Name {
-			klog.V(3).Infof("using override: %s=%s", rls.ChartName, override.Version)
-			rls.Latest = output.VersionInfo{Version: override.Version}
-			rls.IsOld = version.Compare(rls.Installed.Version, override.Version, "<")
-			rls.Overridden = true
+	var override *DesiredVersion
+	for i, d := range h.DesiredVersions {
+		if d.matches(rls) && (override == nil || d.specificity() > override.specificity()) {
+			override = &h.DesiredVersions[i]
+		}
+	}
+	if override == nil {
+		return
+	}
+	if _, err := versions.Parse(override.Version); err == nil {
+		klog.V(3).Infof("using override: %s=%s", rls.ChartName, override.Version)
+		rls.Latest = output.VersionInfo{Version: override.Version}
+		// keep the app and kube version of the override if it is a known version of the chart
+		for _, available := range rls.AvailableVersions {
+			if available.Version == override.Version {
+				rls.Latest = available
+				break
+			}
+		}
+		rls.IsOld = versions.Newer(override.Version, rls.Installed.Version)
+		rls.Overridden = true
+		return
+	}
+	constraint, err := semver.NewConstraint(override.Version)
+	if err != nil {
+		klog.Errorf("invalid desired version %q for %s: %v", override.Version, override.scope(), err)
+		return
+	}
+	for _, available := range rls.AvailableVersions {
+		v, err := versions.Parse(available.Version)
+		if err != nil || !constraint.Check(v) {
+			continue
 		}
+		klog.V(3).Infof("using override: %s=%s, newest version satisfying %s", rls.ChartName, available.Version, override.Version)
+		rls.NewestVersion = rls.Latest.Version
+		rls.DesiredVersion = override.Version
+		rls.Latest = available
+		rls.IsOld = versions.Newer(available.Version, rls.Installed.Version)
+		rls.Overridden = true
+		return
 	}
+	klog.V(2).Infof("no available version of %s in release %s/%s satisfies %s", rls.ChartName, rls.Namespace, rls.ReleaseName, override.Version)
 }
 
 // filterIgnoredReleases is a helper function that removes charts that match a release name or chart name
@@ -17,10 +17,13 @@ package helm
 import (
 	"testing"
 
+	"github.com/fairwindsops/nova/pkg/kube"
 	"github.com/fairwindsops/nova/pkg/output"
 	"github.com/stretchr/testify/assert"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
+	helmdriver "helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestHelm_OverrideDesiredVersion(t *testing.T) {
@@ -73,6 +76,132 @@ func TestHelm_OverrideDesiredVersion(t *testing.T) {
 				Overridden: true,
 			},
 		},
+		{
+			name: "Override desiredVersions with a known version",
+			desiredVersions: []DesiredVersion{
+				{
+					Name:    "test-chart",
+					Version: "1.5.0",
+				},
+			},
+			input: &output.ReleaseOutput{
+				ChartName: "test-chart",
+				Installed: output.VersionInfo{Version: "1.4.0"},
+				Latest:    output.VersionInfo{Version: "2.1.0"},
+				AvailableVersions: []output.VersionInfo{
+					{Version: "2.1.0"},
+					{Version: "1.5.0", AppVersion: "3.3.0", KubeVersion: ">=1.25.0-0"},
+				},
+			},
+			want: &output.ReleaseOutput{
+				ChartName: "test-chart",
+				Installed: output.VersionInfo{Version: "1.4.0"},
+				Latest:    output.VersionInfo{Version: "1.5.0", AppVersion: "3.3.0", KubeVersion: ">=1.25.0-0"},
+				AvailableVersions: []output.VersionInfo{
+					{Version: "2.1.0"},
+					{Version: "1.5.0", AppVersion: "3.3.0", KubeVersion: ">=1.25.0-0"},
+				},
+				IsOld:      true,
+				Overridden: true,
+			},
+		},
+		{
+			name: "Constraint desiredVersions",
+			desiredVersions: []DesiredVersion{
+				{
+					Name:    "test-chart",
+					Version: "~1.4",
+				},
+			},
+			input: &output.ReleaseOutput{
+				ChartName: "test-chart",
+				Installed: output.VersionInfo{Version: "1.4.0"},
+				Latest:    output.VersionInfo{Version: "2.1.0"},
+				AvailableVersions: []output.VersionInfo{
+					{Version: "2.1.0"},
+					{Version: "1.5.0"},
+					{Version: "1.4.2", AppVersion: "3.2.1"},
+					{Version: "1.4.0"},
+				},
+			},
+			want: &output.ReleaseOutput{
+				ChartName: "test-chart",
+				Installed: output.VersionInfo{Version: "1.4.0"},
+				Latest:    output.VersionInfo{Version: "1.4.2", AppVersion: "3.2.1"},
+				AvailableVersions: []output.VersionInfo{
+					{Version: "2.1.0"},
+					{Version: "1.5.0"},
+					{Version: "1.4.2", AppVersion: "3.2.1"},
+					{Version: "1.4.0"},
+				},
+				IsOld:          true,
+				Overridden:     true,
+				DesiredVersion: "~1.4",
+				NewestVersion:  "2.1.0",
+			},
+		},
+		{
+			name: "Constraint not satisfied",
+			desiredVersions: []DesiredVersion{
+				{
+					Name:    "test-chart",
+					Version: "^3.0.0",
+				},
+			},
+			input: &output.ReleaseOutput{
+				ChartName:         "test-chart",
+				Installed:         output.VersionInfo{Version: "1.4.0"},
+				Latest:            output.VersionInfo{Version: "2.1.0"},
+				AvailableVersions: []output.VersionInfo{{Version: "2.1.0"}, {Version: "1.4.0"}},
+				IsOld:             true,
+			},
+			want: &output.ReleaseOutput{
+				ChartName:         "test-chart",
+				Installed:         output.VersionInfo{Version: "1.4.0"},
+				Latest:            output.VersionInfo{Version: "2.1.0"},
+				AvailableVersions: []output.VersionInfo{{Version: "2.1.0"}, {Version: "1.4.0"}},
+				IsOld:             true,
+			},
+		},
+		{
+			name: "Most specific desiredVersions",
+			desiredVersions: []DesiredVersion{
+				{
+					Release: "test-release",
+					Version: "1.0.0",
+				},
+				{
+					Name:    "test-chart",
+					Version: "2.0.0",
+				},
+				{
+					Name:      "test-chart",
+					Namespace: "test-namespace",
+					Version:   "3.0.0",
+				},
+				{
+					Name:      "test-chart",
+					Namespace: "other-namespace",
+					Release:   "test-release",
+					Version:   "4.0.0",
+				},
+			},
+			input: &output.ReleaseOutput{
+				ReleaseName: "test-release",
+				ChartName:   "test-chart",
+				Namespace:   "test-namespace",
+				Installed:   output.VersionInfo{Version: "0.1.0"},
+			},
+			want: &output.ReleaseOutput{
+				ReleaseName: "test-release",
+				ChartName:   "test-chart",
+				Namespace:   "test-namespace",
+				Installed:   output.VersionInfo{Version: "0.1.0"},
+				Latest:      output.VersionInfo{Version: "1.0.0"},
+				IsOld:       true,
+				Overridden:  true,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -85,6 +214,31 @@ func TestHelm_OverrideDesiredVersion(t *testing.T) {
 	}
 }
 
+func TestDesiredVersion_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired DesiredVersion
+		wantErr bool
+	}{
+		{name: "exact version", desired: DesiredVersion{Name: "chart", Version: "1.2.3"}},
+		{name: "constraint", desired: DesiredVersion{Name: "chart", Version: ">= 1.2, < 2.0"}},
+		{name: "release scope", desired: DesiredVersion{Release: "release", Version: "^3.0.0"}},
+		{name: "no scope", desired: DesiredVersion{Namespace: "namespace", Version: "1.2.3"}, wantErr: true},
+		{name: "no version", desired: DesiredVersion{Name: "chart"}, wantErr: true},
+		{name: "invalid constraint", desired: DesiredVersion{Name: "chart", Version: "~>one"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.desired.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestHelm_filterIgnoredReleases(t *testing.T) {
 	tests := []struct {
 		name              string             // Name of test case
@@ -265,3 +419,70 @@ func TestHelm_filterIgnoredReleases(t *testing.T) {
 		})
 	}
 }
+
+func TestHelm_GetHelmReleases_Drivers(t *testing.T) {
+	newRelease := func(name string) *release.Release {
+		return &release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{Name: name, Version: "1.0.0"},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset()
+	secrets := helmdriver.NewSecrets(client.CoreV1().Secrets("default"))
+	assert.NoError(t, secrets.Create("sh.helm.release.v1.from-secret.v1", newRelease("from-secret")))
+	configMaps := helmdriver.NewConfigMaps(client.CoreV1().ConfigMaps("default"))
+	assert.NoError(t, configMaps.Create("sh.helm.release.v1.from-configmap.v1", newRelease("from-configmap")))
+
+	tests := []struct {
+		driver  string
+		want    []string
+		wantErr bool
+	}{
+		{driver: "", want: []string{"from-secret"}},
+		{driver: DriverSecret, want: []string{"from-secret"}},
+		{driver: DriverConfigMap, want: []string{"from-configmap"}},
+		{driver: DriverAuto, want: []string{"from-secret", "from-configmap"}},
+		{driver: DriverSQL, wantErr: true},
+		{driver: "memory", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			h := &Helm{
+				Kube:   &kube.Connection{Client: client},
+				Driver: tt.driver,
+			}
+			got, err := h.GetHelmReleases("default", nil, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			names := []string{}
+			for _, r := range got {
+				names = append(names, r.Name)
+			}
+			assert.ElementsMatch(t, tt.want, names)
+		})
+	}
+}
+
+func Test_latestRevisions(t *testing.T) {
+	input := []*release.Release{
+		{Name: "foo", Namespace: "a", Version: 1, Info: &release.Info{Status: release.StatusSuperseded}},
+		{Name: "foo", Namespace: "a", Version: 2, Info: &release.Info{Status: release.StatusFailed}},
+		{Name: "foo", Namespace: "b", Version: 1, Info: &release.Info{Status: release.StatusDeployed}},
+		{Name: "bar", Namespace: "a", Version: 3, Info: &release.Info{Status: release.StatusPendingUpgrade}},
+		{Name: "bar", Namespace: "a", Version: 2, Info: &release.Info{Status: release.StatusDeployed}},
+		{Name: "gone", Namespace: "a", Version: 1, Info: &release.Info{Status: release.StatusUninstalled}},
+	}
+	got := latestRevisions(input)
+	assert.Len(t, got, 3)
+	assert.Equal(t, release.StatusFailed, got[0].Info.Status)
+	assert.Equal(t, "b", got[1].Namespace)
+	assert.Equal(t, release.StatusPendingUpgrade, got[2].Info.Status)
+}
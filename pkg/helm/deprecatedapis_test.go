@@ -0,0 +1,104 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const testManifest = `---
+# Source: test/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+---
+# Source: test/templates/cronjob.yaml
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: test-cleanup
+---
+# Source: test/templates/hpa.yaml
+apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: test
+---
+# Source: test/templates/ingress.yaml
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: test
+`
+
+func TestFindDeprecatedAPIs(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeVersion string
+		want        []output.DeprecatedAPI
+	}{
+		{
+			name:        "no kubernetes version",
+			kubeVersion: "",
+			want:        nil,
+		},
+		{
+			name:        "before deprecation",
+			kubeVersion: "1.18.0",
+			want:        nil,
+		},
+		{
+			name:        "deprecated",
+			kubeVersion: "1.21.5",
+			want: []output.DeprecatedAPI{
+				{Kind: "CronJob", Name: "test-cleanup", APIVersion: "batch/v1beta1", DeprecatedIn: "1.21", RemovedIn: "1.25", Replacement: "batch/v1"},
+				{Kind: "Ingress", Name: "test", APIVersion: "networking.k8s.io/v1beta1", DeprecatedIn: "1.19", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1"},
+			},
+		},
+		{
+			name:        "removed",
+			kubeVersion: "1.25.0",
+			want: []output.DeprecatedAPI{
+				{Kind: "CronJob", Name: "test-cleanup", APIVersion: "batch/v1beta1", DeprecatedIn: "1.21", RemovedIn: "1.25", Removed: true, Replacement: "batch/v1"},
+				{Kind: "HorizontalPodAutoscaler", Name: "test", APIVersion: "autoscaling/v2beta2", DeprecatedIn: "1.23", RemovedIn: "1.26", Replacement: "autoscaling/v2"},
+				{Kind: "Ingress", Name: "test", APIVersion: "networking.k8s.io/v1beta1", DeprecatedIn: "1.19", RemovedIn: "1.22", Removed: true, Replacement: "networking.k8s.io/v1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FindDeprecatedAPIs(testManifest, tt.kubeVersion))
+		})
+	}
+}
+
+func TestAddDeprecatedAPIs(t *testing.T) {
+	releases := []output.ReleaseOutput{
+		{ReleaseName: "test", Namespace: "default"},
+		{ReleaseName: "test", Namespace: "other"},
+	}
+	helmReleases := []*release.Release{
+		{Name: "test", Namespace: "default", Manifest: testManifest},
+		{Name: "test", Namespace: "other", Manifest: ""},
+	}
+	AddDeprecatedAPIs(releases, helmReleases, "1.22.0")
+	assert.Len(t, releases[0].DeprecatedAPIs, 2)
+	assert.Empty(t, releases[1].DeprecatedAPIs)
+}
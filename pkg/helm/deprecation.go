@@ -0,0 +1,93 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"gopkg.in/yaml.v2"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// artifactHubLinksAnnotation is the chart annotation artifacthub reads additional links from
+const artifactHubLinksAnnotation = "artifacthub.io/links"
+
+// deprecationReasonAnnotations are chart annotation names, without their domain prefix, that explain a deprecation
+var deprecationReasonAnnotations = []string{"deprecation-message", "deprecationMessage", "deprecation-reason", "deprecationReason"}
+
+// replacementLinkNames are words in link names that point to the successor of a deprecated chart
+var replacementLinkNames = []string{"replacement", "successor", "migration", "moved"}
+
+var urlRegexp = regexp.MustCompile(`https?://[^\s"'<>)]+`)
+
+// DeprecationInfo explains why a deprecated chart is deprecated and what replaces it. The reason comes from a
+// deprecation annotation of the installed chart, or from the description of the latest or installed chart when it
+// mentions the deprecation. The replacement is a link named like a replacement, a url in the reason, or the first
+// chart the installed chart possibly moved to.
+func DeprecationInfo(installed *chart.Metadata, latestDescription string, links []Link, moved []output.MovedChart) (reason, replacement string) {
+	reason = deprecationReason(installed, latestDescription)
+
+	allLinks := append(chartLinks(installed), links...)
+	for _, link := range allLinks {
+		name := strings.ToLower(link.Name)
+		for _, n := range replacementLinkNames {
+			if strings.Contains(name, n) && link.URL != "" {
+				return reason, link.URL
+			}
+		}
+	}
+	if u := urlRegexp.FindString(reason); u != "" {
+		return reason, strings.TrimRight(u, ".,;")
+	}
+	if len(moved) > 0 {
+		return reason, moved[0].Repository + "/" + moved[0].ChartName
+	}
+	return reason, ""
+}
+
+func deprecationReason(installed *chart.Metadata, latestDescription string) string {
+	if installed != nil {
+		for key, value := range installed.Annotations {
+			name := key[strings.LastIndex(key, "/")+1:]
+			if containsString(deprecationReasonAnnotations, name) && strings.TrimSpace(value) != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	descriptions := []string{latestDescription}
+	if installed != nil {
+		descriptions = append(descriptions, installed.Description)
+	}
+	for _, d := range descriptions {
+		if strings.Contains(strings.ToLower(d), "deprecated") {
+			return strings.TrimSpace(d)
+		}
+	}
+	return ""
+}
+
+// chartLinks returns the links from the artifacthub.io/links annotation of a chart
+func chartLinks(metadata *chart.Metadata) []Link {
+	if metadata == nil || metadata.Annotations[artifactHubLinksAnnotation] == "" {
+		return nil
+	}
+	var links []Link
+	if err := yaml.Unmarshal([]byte(metadata.Annotations[artifactHubLinksAnnotation]), &links); err != nil {
+		return nil
+	}
+	return links
+}
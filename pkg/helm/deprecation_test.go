@@ -0,0 +1,79 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestDeprecationInfo(t *testing.T) {
+	tests := []struct {
+		name              string
+		installed         *chart.Metadata
+		latestDescription string
+		links             []Link
+		moved             []output.MovedChart
+		wantReason        string
+		wantReplacement   string
+	}{
+		{
+			name:              "description with url",
+			installed:         &chart.Metadata{Description: "An nginx ingress controller"},
+			latestDescription: "DEPRECATED - ingress-nginx has moved to https://kubernetes.github.io/ingress-nginx.",
+			wantReason:        "DEPRECATED - ingress-nginx has moved to https://kubernetes.github.io/ingress-nginx.",
+			wantReplacement:   "https://kubernetes.github.io/ingress-nginx",
+		},
+		{
+			name: "annotations",
+			installed: &chart.Metadata{
+				Annotations: map[string]string{
+					"example.com/deprecation-message": "Use the operator instead",
+					"artifacthub.io/links":            "- name: source\n  url: https://github.com/example/chart\n- name: Replacement chart\n  url: https://artifacthub.io/packages/helm/example/operator\n",
+				},
+			},
+			wantReason:      "Use the operator instead",
+			wantReplacement: "https://artifacthub.io/packages/helm/example/operator",
+		},
+		{
+			name:            "artifacthub links and moved chart",
+			installed:       &chart.Metadata{Description: "This chart is deprecated"},
+			links:           []Link{{Name: "successor", URL: "https://example.com/new"}},
+			moved:           []output.MovedChart{{Repository: "repo", ChartName: "new"}},
+			wantReason:      "This chart is deprecated",
+			wantReplacement: "https://example.com/new",
+		},
+		{
+			name:            "moved chart",
+			installed:       &chart.Metadata{Description: "A chart"},
+			moved:           []output.MovedChart{{Repository: "repo", ChartName: "new"}},
+			wantReason:      "",
+			wantReplacement: "repo/new",
+		},
+		{
+			name: "nothing known",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, replacement := DeprecationInfo(tt.installed, tt.latestDescription, tt.links, tt.moved)
+			assert.Equal(t, tt.wantReason, reason)
+			assert.Equal(t, tt.wantReplacement, replacement)
+		})
+	}
+}
@@ -15,70 +15,283 @@
 package helm
 
 import (
+	"fmt"
 	"slices"
-	"strings"
+	"sort"
 
 	"github.com/fairwindsops/nova/pkg/output"
-	version "github.com/mcuadros/go-version"
+	"github.com/fairwindsops/nova/pkg/versions"
 	"helm.sh/helm/v3/pkg/release"
 	"k8s.io/klog/v2"
 )
 
 const useStarCountThreshold = 10
 
-type packageKey struct {
-	Name       string
-	Repository string
+// ArtifactHubMatcher matches helm releases to artifacthub packages. The zero value uses the default scoring.
+type ArtifactHubMatcher struct {
+	// Pins match a chart or release to a package from a specific artifacthub repository instead of scoring the candidates
+	Pins []RepositoryPin `mapstructure:"-"`
+	// Weights override the points scored by each criterion, keyed by criterion name
+	Weights map[string]float32 `mapstructure:"weights"`
+	// PreferredRepositories are scored as preferred in addition to the built-in list of well known repositories
+	PreferredRepositories []string `mapstructure:"preferred-repositories"`
+	// BlockedRepositories are never matched unless they are pinned
+	BlockedRepositories []string `mapstructure:"blocked-repositories"`
+	// StarThreshold is the star count a package must reach for the most starred package to get a bonus. Defaults to 10.
+	StarThreshold int `mapstructure:"star-threshold"`
+	// MinScore is the score the best package must reach to be matched. Packages scoring zero are never matched.
+	MinScore float32 `mapstructure:"min-score"`
+	// AmbiguityMargin is how close the runner-up must score to the best package for the match to be ambiguous. Defaults to 0.5.
+	AmbiguityMargin float32 `mapstructure:"ambiguity-margin"`
+	// IncludePrereleases considers pre-release versions of the matched package when looking for the newest version
+	IncludePrereleases bool `mapstructure:"-"`
+}
+
+const defaultAmbiguityMargin = 0.5
+
+// Names of the criteria used to score artifacthub packages
+const (
+	criterionHomeURL            = "home-url"
+	criterionDescription        = "description"
+	criterionSourceLink         = "source-link"
+	criterionMaintainers        = "maintainers"
+	criterionVerifiedPublisher  = "verified-publisher"
+	criterionOfficialPackage    = "official-package"
+	criterionOfficialRepository = "official-repository"
+	criterionVersionExists      = "version-exists"
+	criterionPreferredRepo      = "preferred-repository"
+	// criterionStars is the bonus for the most starred package
+	criterionStars = "stars"
+)
+
+// defaultWeights are the points scored by each criterion
+var defaultWeights = map[string]float32{
+	criterionHomeURL:            1,
+	criterionDescription:        1,
+	criterionSourceLink:         1,
+	criterionMaintainers:        1,
+	criterionVerifiedPublisher:  1,
+	criterionOfficialPackage:    1,
+	criterionOfficialRepository: 1,
+	criterionVersionExists:      1,
+	criterionPreferredRepo:      1.5,
+	criterionStars:              1,
+}
+
+// Validate returns an error if the matcher configuration is invalid
+func (m *ArtifactHubMatcher) Validate() error {
+	for name := range m.Weights {
+		if _, ok := defaultWeights[name]; !ok {
+			return fmt.Errorf("unknown scoring criterion %q", name)
+		}
+	}
+	for _, p := range m.Pins {
+		if p.Repository == "" || (p.Chart == "" && p.Release == "") {
+			return fmt.Errorf("artifacthub pins need a repository and a chart or release")
+		}
+	}
+	return nil
+}
+
+func (m *ArtifactHubMatcher) weight(criterion string) float32 {
+	if w, ok := m.Weights[criterion]; ok {
+		return w
+	}
+	return defaultWeights[criterion]
+}
+
+func (m *ArtifactHubMatcher) starThreshold() int {
+	if m.StarThreshold > 0 {
+		return m.StarThreshold
+	}
+	return useStarCountThreshold
+}
+
+func (m *ArtifactHubMatcher) ambiguityMargin() float32 {
+	if m.AmbiguityMargin > 0 {
+		return m.AmbiguityMargin
+	}
+	return defaultAmbiguityMargin
+}
+
+func (m *ArtifactHubMatcher) isPreferred(repository string) bool {
+	return containsString(preferredRepositories, repository) || containsString(m.PreferredRepositories, repository)
+}
+
+// RepositoryPin matches releases of a chart, or a specific release, to the package in an artifacthub repository.
+// Chart, Release and Namespace are optional, but at least one of Chart and Release must be set.
+type RepositoryPin struct {
+	Chart      string `mapstructure:"chart"`
+	Release    string `mapstructure:"release"`
+	Namespace  string `mapstructure:"namespace"`
+	Repository string `mapstructure:"repository"`
+}
+
+// matches returns true if the pin applies to the release
+func (p RepositoryPin) matches(clusterRelease *release.Release) bool {
+	if p.Chart == "" && p.Release == "" {
+		return false
+	}
+	return (p.Chart == "" || p.Chart == clusterRelease.Chart.Metadata.Name) &&
+		(p.Release == "" || p.Release == clusterRelease.Name) &&
+		(p.Namespace == "" || p.Namespace == clusterRelease.Namespace)
+}
+
+// specificity ranks pins so that a pin for a release in a namespace wins over a pin for a chart
+func (p RepositoryPin) specificity() int {
+	ret := 0
+	for _, field := range []string{p.Chart, p.Release, p.Namespace} {
+		if field != "" {
+			ret++
+		}
+	}
+	return ret
+}
+
+// pin returns the most specific pin for the release, if any
+func (m *ArtifactHubMatcher) pin(clusterRelease *release.Release) *RepositoryPin {
+	var ret *RepositoryPin
+	for i, p := range m.Pins {
+		if p.matches(clusterRelease) && (ret == nil || p.specificity() > ret.specificity()) {
+			ret = &m.Pins[i]
+		}
+	}
+	return ret
 }
 
 // FindBestArtifactHubMatch takes the helm releases found in the cluster and attempts to match those to a package in artifacthub
 func FindBestArtifactHubMatch(clusterRelease *release.Release, ahubPackages []ArtifactHubHelmPackage) *output.ReleaseOutput {
-	packagesByName := map[packageKey]ArtifactHubHelmPackage{}
-	packageScores := map[packageKey]float32{}
-	packageStars := map[packageKey]int{}
+	return (&ArtifactHubMatcher{}).FindBestMatch(clusterRelease, ahubPackages)
+}
+
+// ExplainArtifactHubMatch scores every artifacthub package with the same chart name as the release, see ArtifactHubMatcher.Explain
+func ExplainArtifactHubMatch(clusterRelease *release.Release, ahubPackages []ArtifactHubHelmPackage) (*output.MatchInfo, *ArtifactHubHelmPackage) {
+	return (&ArtifactHubMatcher{}).Explain(clusterRelease, ahubPackages)
+}
+
+// FindBestMatch attempts to match a helm release found in the cluster to a package in artifacthub
+func (m *ArtifactHubMatcher) FindBestMatch(clusterRelease *release.Release, ahubPackages []ArtifactHubHelmPackage) *output.ReleaseOutput {
+	match, pkg := m.Explain(clusterRelease, ahubPackages)
+	var best ArtifactHubHelmPackage
+	if pkg != nil {
+		best = *pkg
+	}
+	rls := prepareOutput(clusterRelease, best)
+	if pkg != nil {
+		SetUpgradePaths(rls, packageVersions(best), m.IncludePrereleases)
+		if m.IncludePrereleases && len(rls.AvailableVersions) > 0 && versions.Newer(rls.AvailableVersions[0].Version, rls.Latest.Version) {
+			rls.Latest = rls.AvailableVersions[0]
+			rls.IsOld = versions.Newer(rls.Latest.Version, rls.Installed.Version)
+		}
+	}
+	rls.Match = match
+	if pkg != nil && match.PinnedRepository == "" {
+		rls.MatchScore = match.Candidates[0].Score
+		rls.Ambiguous = match.Ambiguous
+	}
+	if pkg == nil || pkg.Deprecated {
+		rls.PossiblyMovedTo = m.FindMovedCharts(clusterRelease, ahubPackages, best.Repository.Name)
+	}
+	if rls.Deprecated {
+		rls.DeprecationReason, rls.Replacement = DeprecationInfo(clusterRelease.Chart.Metadata, best.Description, best.Links, rls.PossiblyMovedTo)
+	}
+	return rls
+}
+
+// Explain scores every artifacthub package with the same chart name as the release. The candidates are
+// sorted from best to worst, and the best one is selected and returned if it scored above zero.
+// If the release is pinned to a repository, the package from that repository is selected without scoring.
+func (m *ArtifactHubMatcher) Explain(clusterRelease *release.Release, ahubPackages []ArtifactHubHelmPackage) (*output.MatchInfo, *ArtifactHubHelmPackage) {
+	if pin := m.pin(clusterRelease); pin != nil {
+		return explainPinnedMatch(clusterRelease, ahubPackages, pin.Repository)
+	}
+	type scoredPackage struct {
+		candidate output.MatchCandidate
+		pkg       ArtifactHubHelmPackage
+	}
+	var scored []scoredPackage
 	var useStars bool
+	highestStars := -1
 	for _, p := range ahubPackages {
 		if p.Name != clusterRelease.Chart.Metadata.Name {
 			continue
 		}
-
-		key := packageKey{Name: p.Name, Repository: p.Repository.Name}
-		packageScores[key] = scoreChartSimilarity(clusterRelease, p)
-		packagesByName[key] = p
-		packageStars[key] = p.Stars
-
-		if p.Stars >= useStarCountThreshold {
-			useStars = true // If any package has more than 10 stars, we add a point to the highest star package
+		if containsString(m.BlockedRepositories, p.Repository.Name) {
+			klog.V(10).Infof("skipping blocked repository %s for %s", p.Repository.Name, p.Name)
+			continue
+		}
+		criteria := m.scoreCriteria(clusterRelease, p)
+		scored = append(scored, scoredPackage{
+			candidate: output.MatchCandidate{
+				Hub:        p.Hub,
+				Repository: p.Repository.Name,
+				Package:    p.Name,
+				Version:    p.Version,
+				Stars:      p.Stars,
+				Criteria:   criteria,
+				Score:      sumCriteria(criteria),
+			},
+			pkg: p,
+		})
+		if p.Stars >= m.starThreshold() {
+			useStars = true // If any package reaches the threshold, the highest star package gets a bonus
 		}
+		if p.Stars > 0 && (highestStars == -1 || p.Stars > scored[highestStars].pkg.Stars) {
+			highestStars = len(scored) - 1
+		}
+	}
+	if useStars && highestStars != -1 {
+		k := scored[highestStars].candidate
+		klog.V(10).Infof("adding a bonus to the highest star package: %s:%s", k.Repository, k.Package)
+		scored[highestStars].candidate.StarBonus = m.weight(criterionStars)
+		scored[highestStars].candidate.Score += m.weight(criterionStars)
 	}
 
-	var highestStarPackageName *packageKey
-	var highStars int
-	for p, stars := range packageStars {
-		if stars > highStars {
-			highStars = stars
-			highestStarPackageName = &p
-		}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].candidate.Score > scored[j].candidate.Score
+	})
+	match := &output.MatchInfo{Candidates: make([]output.MatchCandidate, len(scored))}
+	for i := range scored {
+		match.Candidates[i] = scored[i].candidate
+	}
+	if len(scored) == 0 || scored[0].candidate.Score <= 0 || scored[0].candidate.Score < m.MinScore {
+		klog.V(10).Infof("no artifacthub match for '%s'", clusterRelease.Chart.Metadata.Name)
+		return match, nil
+	}
+	match.Candidates[0].Selected = true
+	if len(scored) > 1 && scored[0].candidate.Score-scored[1].candidate.Score < m.ambiguityMargin() {
+		klog.V(5).Infof("ambiguous artifacthub match for '%s': %s scored %g, %s scored %g", clusterRelease.Chart.Metadata.Name,
+			scored[0].pkg.Repository.Name, scored[0].candidate.Score, scored[1].pkg.Repository.Name, scored[1].candidate.Score)
+		match.Ambiguous = true
 	}
+	klog.V(10).Infof("highScore for '%s': %f, highScorePackage Repo: %s", clusterRelease.Chart.Metadata.Name, scored[0].candidate.Score, scored[0].pkg.Repository.Name)
+	return match, &scored[0].pkg
+}
 
-	var highScore float32
-	var highScorePackage ArtifactHubHelmPackage
-	for k, score := range packageScores {
-		if useStars && highestStarPackageName != nil && k == *highestStarPackageName {
-			klog.V(10).Infof("adding a point to the highest star package: %s:%s", k.Repository, k.Name)
-			score++ // Add a point to the highest star package
-		}
-		if score > highScore {
-			highScore = score
-			highScorePackage = packagesByName[k]
+// explainPinnedMatch selects the package of the release's chart from the pinned repository
+func explainPinnedMatch(clusterRelease *release.Release, ahubPackages []ArtifactHubHelmPackage, repository string) (*output.MatchInfo, *ArtifactHubHelmPackage) {
+	match := &output.MatchInfo{PinnedRepository: repository, Candidates: []output.MatchCandidate{}}
+	for i, p := range ahubPackages {
+		if p.Name != clusterRelease.Chart.Metadata.Name || p.Repository.Name != repository {
+			continue
 		}
+		klog.V(10).Infof("using pinned repository %s for release %s/%s", repository, clusterRelease.Namespace, clusterRelease.Name)
+		match.Candidates = append(match.Candidates, output.MatchCandidate{
+			Hub:        p.Hub,
+			Repository: p.Repository.Name,
+			Package:    p.Name,
+			Version:    p.Version,
+			Stars:      p.Stars,
+			Selected:   true,
+		})
+		return match, &ahubPackages[i]
 	}
-	klog.V(10).Infof("highScore for '%s': %f, highScorePackage Repo: %s", clusterRelease.Chart.Metadata.Name, highScore, highScorePackage.Repository.Name)
-	return prepareOutput(clusterRelease, highScorePackage)
+	klog.Warningf("artifacthub repository %s pinned for release %s/%s does not contain chart %s", repository, clusterRelease.Namespace, clusterRelease.Name, clusterRelease.Chart.Metadata.Name)
+	return match, nil
 }
 
 func prepareOutput(release *release.Release, pkg ArtifactHubHelmPackage) *output.ReleaseOutput {
-	return &output.ReleaseOutput{
+	rls := &output.ReleaseOutput{
 		ReleaseName: release.Name,
 		ChartName:   release.Chart.Metadata.Name,
 		Namespace:   release.Namespace,
@@ -95,10 +308,12 @@ func prepareOutput(release *release.Release, pkg ArtifactHubHelmPackage) *output
 			AppVersion:  pkg.AppVersion,
 			KubeVersion: pkg.KubeVersion,
 		},
-		IsOld:       version.Compare(release.Chart.Metadata.Version, pkg.Version, "<"),
+		IsOld:       versions.Newer(pkg.Version, release.Chart.Metadata.Version),
 		Deprecated:  pkg.Deprecated,
 		HelmVersion: "3",
 	}
+	rls.SetReleaseInfo(release.Info)
+	return rls
 }
 
 var preferredRepositories = []string{"bitnami", "fairwinds-stable", "fairwinds-incubator", "ingress-nginx", "cert-manager", "projectcalico",
@@ -106,20 +321,27 @@ var preferredRepositories = []string{"bitnami", "fairwinds-stable", "fairwinds-i
 	"aws-ebs-csi-driver", "coredns", "datadog", "deliveryhero", "falcosecurity", "kedacore", "kured", "oauth2-proxy", "rimusz"}
 
 func scoreChartSimilarity(release *release.Release, pkg ArtifactHubHelmPackage) float32 {
-	var ret float32
+	return sumCriteria((&ArtifactHubMatcher{}).scoreCriteria(release, pkg))
+}
+
+// scoreCriteria returns every criterion the package matched for the release, with the points it scored
+func (m *ArtifactHubMatcher) scoreCriteria(release *release.Release, pkg ArtifactHubHelmPackage) []output.MatchCriterion {
+	var ret []output.MatchCriterion
+	add := func(name string, reason string) {
+		score := m.weight(name)
+		klog.V(10).Infof("+%g score for %s %s (ahub package repo %s)", score, release.Chart.Metadata.Name, reason, pkg.Repository.Name)
+		ret = append(ret, output.MatchCriterion{Name: name, Score: score})
+	}
 	if release.Chart.Metadata.Home == pkg.HomeURL {
-		klog.V(10).Infof("+1 score for %s Home URL (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret++
+		add(criterionHomeURL, "Home URL")
 	}
 	if release.Chart.Metadata.Description == pkg.Description {
-		klog.V(10).Infof("+1 score for %s Description (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret++
+		add(criterionDescription, "Description")
 	}
 	for _, source := range pkg.Links {
 		if source.Name == "source" {
 			if containsString(release.Chart.Metadata.Sources, source.URL) {
-				klog.V(10).Infof("+1 score for %s source links (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-				ret++
+				add(criterionSourceLink, "source links")
 			}
 		}
 	}
@@ -134,30 +356,32 @@ func scoreChartSimilarity(release *release.Release, pkg ArtifactHubHelmPackage)
 		}
 	}
 	if matchedMaintainers > 0 {
-		klog.V(10).Infof("+1 score for %s Maintainers (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret++
+		add(criterionMaintainers, "Maintainers")
 	}
 	if pkg.Repository.VerifiedPublisher {
-		klog.V(10).Infof("+1 score for %s verified publisher (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret++
+		add(criterionVerifiedPublisher, "verified publisher")
 	}
 	if pkg.Official {
-		klog.V(10).Infof("+1 score for %s verified publisher (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret++
+		add(criterionOfficialPackage, "official package")
 	}
 	if pkg.Repository.Official {
-		klog.V(10).Infof("+1 score for %s verified publisher (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret++
+		add(criterionOfficialRepository, "official repository")
 	}
 	if clusterVersionExistsInPackage(release.Chart.Metadata.Version, pkg) {
-		klog.V(10).Infof("+1 score for %s, current version exists in available versions (ahub package %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret++
+		add(criterionVersionExists, "current version exists in available versions")
 	}
-	if containsString(preferredRepositories, pkg.Repository.Name) {
-		klog.V(10).Infof("+1.5 score for %s, preferred repo (ahub package repo %s)", release.Chart.Metadata.Name, pkg.Repository.Name)
-		ret += 1.5
+	if m.isPreferred(pkg.Repository.Name) {
+		add(criterionPreferredRepo, "preferred repo")
+	}
+	klog.V(10).Infof("calculated score repo: %s, release: %s, stars: %d, score: %f\n\n", pkg.Repository.Name, release.Name, pkg.Stars, sumCriteria(ret))
+	return ret
+}
+
+func sumCriteria(criteria []output.MatchCriterion) float32 {
+	var ret float32
+	for _, c := range criteria {
+		ret += c.Score
 	}
-	klog.V(10).Infof("calculated score repo: %s, release: %s, stars: %d, score: %f\n\n", pkg.Repository.Name, release.Name, pkg.Stars, ret)
 	return ret
 }
 
@@ -174,29 +398,9 @@ func containsString(arr []string, val string) bool {
 	return slices.Contains(arr, val)
 }
 
-// IsValidRelease returns a bool indicating whether a version string is valid or not.
+// IsValidRelease returns a bool indicating whether a version string is a valid semantic version that is not a pre-release.
+//
+// Deprecated: use versions.IsValid instead.
 func IsValidRelease(version string) bool {
-	var specialForms = []string{
-		"SNAPSHOT",
-		"snapshot",
-		"dev",
-		"alpha",
-		"a",
-		"beta",
-		"b",
-		"RC",
-		"rc",
-		"#",
-		"p",
-		"pl",
-		"weekly",
-		"daily",
-	}
-
-	for _, invalid := range specialForms {
-		if strings.Contains(version, invalid) {
-			return false
-		}
-	}
-	return true
+	return versions.IsValid(version, false)
 }
@@ -174,3 +174,175 @@ func Test_prepareOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestExplainArtifactHubMatch(t *testing.T) {
+	community := ArtifactHubHelmPackage{
+		Name:       "test",
+		Version:    "2.0.0",
+		Stars:      20,
+		Repository: ArtifactHubRepository{Name: "community"},
+	}
+	other := ArtifactHubHelmPackage{Name: "other", Repository: ArtifactHubRepository{Name: "fairwinds-stable"}}
+
+	match, pkg := ExplainArtifactHubMatch(helmRelease, []ArtifactHubHelmPackage{community, ahubPackage, other})
+	if assert.NotNil(t, pkg) {
+		assert.Equal(t, "fairwinds-stable", pkg.Repository.Name)
+	}
+	assert.Equal(t, []output.MatchCandidate{
+		{
+			Repository: "fairwinds-stable",
+			Package:    "test",
+			Version:    "1.0.1",
+			Criteria: []output.MatchCriterion{
+				{Name: "home-url", Score: 1},
+				{Name: "description", Score: 1},
+				{Name: "source-link", Score: 1},
+				{Name: "maintainers", Score: 1},
+				{Name: "verified-publisher", Score: 1},
+				{Name: "version-exists", Score: 1},
+				{Name: "preferred-repository", Score: 1.5},
+			},
+			Score:    7.5,
+			Selected: true,
+		},
+		{
+			Repository: "community",
+			Package:    "test",
+			Version:    "2.0.0",
+			Stars:      20,
+			StarBonus:  1,
+			Score:      1,
+		},
+	}, match.Candidates)
+
+	match, pkg = ExplainArtifactHubMatch(helmRelease, []ArtifactHubHelmPackage{other})
+	assert.Nil(t, pkg)
+	assert.Empty(t, match.Candidates)
+
+	got := FindBestArtifactHubMatch(helmRelease, []ArtifactHubHelmPackage{community, ahubPackage})
+	assert.Equal(t, "1.0.1", got.Latest.Version)
+	assert.Len(t, got.Match.Candidates, 2)
+}
+
+func TestArtifactHubMatcher_Pins(t *testing.T) {
+	internal := ArtifactHubHelmPackage{Name: "test", Version: "0.9.0", Repository: ArtifactHubRepository{Name: "internal"}}
+	packages := []ArtifactHubHelmPackage{ahubPackage, internal}
+
+	tests := []struct {
+		name       string
+		pins       []RepositoryPin
+		want       string
+		wantPinned string
+	}{
+		{name: "no pins", want: "fairwinds-stable"},
+		{name: "chart pin", pins: []RepositoryPin{{Chart: "test", Repository: "internal"}}, want: "internal", wantPinned: "internal"},
+		{name: "other chart", pins: []RepositoryPin{{Chart: "other", Repository: "internal"}}, want: "fairwinds-stable"},
+		{
+			name: "release pin wins over chart pin",
+			pins: []RepositoryPin{
+				{Chart: "test", Repository: "internal"},
+				{Release: "test", Namespace: "test", Repository: "fairwinds-stable"},
+			},
+			want:       "fairwinds-stable",
+			wantPinned: "fairwinds-stable",
+		},
+		{name: "other namespace", pins: []RepositoryPin{{Release: "test", Namespace: "other", Repository: "internal"}}, want: "fairwinds-stable"},
+		{name: "pinned repository without the chart", pins: []RepositoryPin{{Chart: "test", Repository: "gone"}}, want: "", wantPinned: "gone"},
+		{name: "pin without chart or release", pins: []RepositoryPin{{Namespace: "test", Repository: "internal"}}, want: "fairwinds-stable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ArtifactHubMatcher{Pins: tt.pins}
+			match, pkg := m.Explain(helmRelease, packages)
+			assert.Equal(t, tt.wantPinned, match.PinnedRepository)
+			if tt.want == "" {
+				assert.Nil(t, pkg)
+				return
+			}
+			if assert.NotNil(t, pkg) {
+				assert.Equal(t, tt.want, pkg.Repository.Name)
+			}
+		})
+	}
+}
+
+func TestArtifactHubMatcher_Config(t *testing.T) {
+	internal := ArtifactHubHelmPackage{
+		Name:        "test",
+		Version:     "0.9.0",
+		Description: "This is a chart.",
+		Repository:  ArtifactHubRepository{Name: "internal"},
+	}
+	popular := ArtifactHubHelmPackage{Name: "test", Version: "3.0.0", Stars: 30, Repository: ArtifactHubRepository{Name: "popular"}}
+	packages := []ArtifactHubHelmPackage{ahubPackage, internal, popular}
+
+	tests := []struct {
+		name    string
+		matcher ArtifactHubMatcher
+		want    string
+	}{
+		{name: "defaults", want: "fairwinds-stable"},
+		{name: "blocked", matcher: ArtifactHubMatcher{BlockedRepositories: []string{"fairwinds-stable"}}, want: "internal"},
+		{
+			name:    "without preferred",
+			matcher: ArtifactHubMatcher{BlockedRepositories: []string{"fairwinds-stable"}, Weights: map[string]float32{"description": 0}},
+			want:    "popular",
+		},
+		{
+			name: "preferred",
+			matcher: ArtifactHubMatcher{
+				BlockedRepositories:   []string{"fairwinds-stable"},
+				PreferredRepositories: []string{"internal"},
+				Weights:               map[string]float32{"description": 0},
+			},
+			want: "internal",
+		},
+		{
+			name:    "star bonus",
+			matcher: ArtifactHubMatcher{BlockedRepositories: []string{"fairwinds-stable"}, Weights: map[string]float32{"stars": 5}},
+			want:    "popular",
+		},
+		{
+			name:    "star threshold not reached",
+			matcher: ArtifactHubMatcher{BlockedRepositories: []string{"fairwinds-stable"}, Weights: map[string]float32{"stars": 5}, StarThreshold: 100},
+			want:    "internal",
+		},
+		{name: "min score", matcher: ArtifactHubMatcher{MinScore: 8}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.matcher.Validate())
+			_, pkg := tt.matcher.Explain(helmRelease, packages)
+			if tt.want == "" {
+				assert.Nil(t, pkg)
+				return
+			}
+			if assert.NotNil(t, pkg) {
+				assert.Equal(t, tt.want, pkg.Repository.Name)
+			}
+		})
+	}
+
+	assert.Error(t, (&ArtifactHubMatcher{Weights: map[string]float32{"typo": 1}}).Validate())
+	assert.Error(t, (&ArtifactHubMatcher{Pins: []RepositoryPin{{Chart: "test"}}}).Validate())
+}
+
+func TestArtifactHubMatcher_Ambiguous(t *testing.T) {
+	first := ArtifactHubHelmPackage{Name: "test", Version: "1.0.0", Description: "This is a chart.", Repository: ArtifactHubRepository{Name: "first"}}
+	second := ArtifactHubHelmPackage{Name: "test", Version: "2.0.0", Description: "This is a chart.", Repository: ArtifactHubRepository{Name: "second"}}
+
+	got := FindBestArtifactHubMatch(helmRelease, []ArtifactHubHelmPackage{first, second})
+	assert.True(t, got.Ambiguous)
+	assert.Equal(t, float32(1), got.MatchScore)
+
+	got = FindBestArtifactHubMatch(helmRelease, []ArtifactHubHelmPackage{first, ahubPackage})
+	assert.False(t, got.Ambiguous)
+	assert.Equal(t, float32(7.5), got.MatchScore)
+
+	got = (&ArtifactHubMatcher{AmbiguityMargin: 7}).FindBestMatch(helmRelease, []ArtifactHubHelmPackage{first, ahubPackage})
+	assert.True(t, got.Ambiguous)
+
+	got = (&ArtifactHubMatcher{MinScore: 2}).FindBestMatch(helmRelease, []ArtifactHubHelmPackage{first, second})
+	assert.Equal(t, "", got.Latest.Version)
+	assert.Equal(t, float32(0), got.MatchScore)
+}
@@ -0,0 +1,354 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/fairwindsops/nova/pkg/versions"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+var (
+	fluxHelmReleaseKind = schema.GroupKind{Group: "helm.toolkit.fluxcd.io", Kind: "HelmRelease"}
+	fluxHelmRepoKind    = schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "HelmRepository"}
+	fluxOCIRepoKind     = schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "OCIRepository"}
+	argoApplicationKind = schema.GroupKind{Group: "argoproj.io", Kind: "Application"}
+)
+
+// GitOpsRelease is a helm chart declared by a Flux HelmRelease or an Argo CD Application
+type GitOpsRelease struct {
+	Owner       output.GitOpsOwner
+	ReleaseName string
+	Namespace   string
+	ChartName   string
+	Version     string
+	RepoURL     string
+}
+
+// GetGitOpsReleases returns the helm charts declared by Flux HelmRelease and Argo CD Application objects in the cluster.
+// Kinds that are not installed in the cluster are skipped.
+func (h *Helm) GetGitOpsReleases(namespace string) ([]GitOpsRelease, error) {
+	var releases []GitOpsRelease
+	fluxReleases, err := h.getFluxReleases(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("could not list flux HelmReleases: %v", err)
+	}
+	releases = append(releases, fluxReleases...)
+	argoReleases, err := h.getArgoReleases(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("could not list argo cd Applications: %v", err)
+	}
+	releases = append(releases, argoReleases...)
+	klog.V(5).Infof("found %d helm charts managed by gitops objects", len(releases))
+	return releases, nil
+}
+
+// GetGitOpsReleasesVersion finds the newest version of each gitops managed chart, using the chart repository declared in
+// the gitops object when possible and falling back to artifacthub packages. The declared repositories use the
+// credentials and TLS settings of the matching repoConfigs and are cached in cache, unless it is nil. Repositories
+// that could not be loaded are returned as errors.
+func (h *Helm) GetGitOpsReleasesVersion(gitopsReleases []GitOpsRelease, ahubPackages []ArtifactHubHelmPackage, repoConfigs []RepoConfig, cache *HTTPCache) ([]output.ReleaseOutput, []output.RepoError) {
+	urls := []string{}
+	configs := []RepoConfig{}
+	for _, r := range gitopsReleases {
+		if (isHTTPRepoURL(r.RepoURL) || IsOCIRepo(r.RepoURL)) && !containsString(urls, r.RepoURL) {
+			urls = append(urls, r.RepoURL)
+			configs = append(configs, RepoConfig{URL: r.RepoURL})
+		}
+	}
+	loaded, repoErrors := NewReposFromConfig(ApplyHelmCredentials(configs, repoConfigs), cache)
+	repos := map[string]*Repo{}
+	for _, repo := range loaded {
+		repos[repo.URL] = repo
+	}
+
+	outputObjects := []output.ReleaseOutput{}
+	for _, r := range gitopsReleases {
+		owner := r.Owner
+		rls := output.ReleaseOutput{
+			ReleaseName: r.ReleaseName,
+			ChartName:   r.ChartName,
+			Namespace:   r.Namespace,
+			Installed:   output.VersionInfo{Version: r.Version},
+			HelmVersion: "3",
+			ManagedBy:   &owner,
+		}
+		if repo, ok := repos[r.RepoURL]; ok {
+			if newest := repo.NewestVersion(r.ChartName, h.IncludePrereleases); newest != nil && newest.Version != "" {
+				rls.Description = newest.Description
+				rls.Home = newest.Home
+				rls.Icon = newest.Icon
+				rls.Latest = output.VersionInfo{
+					Version:     newest.Version,
+					AppVersion:  newest.AppVersion,
+					KubeVersion: newest.KubeVersion,
+				}
+				rls.Deprecated = newest.Deprecated
+				if rls.Deprecated {
+					rls.DeprecationReason, rls.Replacement = DeprecationInfo(nil, newest.Description, nil, nil)
+				}
+				rls.IsOld = versions.Newer(rls.Latest.Version, r.Version)
+				SetUpgradePaths(&rls, repo.ChartVersions(r.ChartName), h.IncludePrereleases)
+			}
+		} else if len(ahubPackages) > 0 {
+			if o := h.matcher().FindBestMatch(r.asRelease(), ahubPackages); o != nil {
+				o.ManagedBy = &owner
+				rls = *o
+			}
+		}
+		h.OverrideDesiredVersion(&rls)
+		outputObjects = append(outputObjects, rls)
+	}
+	return outputObjects, repoErrors
+}
+
+// asRelease builds a minimal helm release so that gitops charts can be scored against artifacthub packages
+func (r GitOpsRelease) asRelease() *release.Release {
+	return &release.Release{
+		Name:      r.ReleaseName,
+		Namespace: r.Namespace,
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    r.ChartName,
+				Version: r.Version,
+			},
+		},
+	}
+}
+
+func (h *Helm) getFluxReleases(namespace string) ([]GitOpsRelease, error) {
+	items, err := h.listKind(fluxHelmReleaseKind, namespace)
+	if err != nil || items == nil {
+		return nil, err
+	}
+	releases := []GitOpsRelease{}
+	for _, hr := range items {
+		r := GitOpsRelease{
+			Owner: output.GitOpsOwner{
+				Kind:      fluxHelmReleaseKind.Kind,
+				Name:      hr.GetName(),
+				Namespace: hr.GetNamespace(),
+			},
+		}
+		targetNamespace, _, _ := unstructured.NestedString(hr.Object, "spec", "targetNamespace")
+		r.Namespace = hr.GetNamespace()
+		if targetNamespace != "" {
+			r.Namespace = targetNamespace
+		}
+		r.ReleaseName, _, _ = unstructured.NestedString(hr.Object, "spec", "releaseName")
+		if r.ReleaseName == "" {
+			r.ReleaseName = hr.GetName()
+			if targetNamespace != "" {
+				r.ReleaseName = targetNamespace + "-" + hr.GetName()
+			}
+		}
+
+		chartName, _, _ := unstructured.NestedString(hr.Object, "spec", "chart", "spec", "chart")
+		if chartName != "" {
+			r.ChartName = chartName
+			r.Version, _, _ = unstructured.NestedString(hr.Object, "spec", "chart", "spec", "version")
+			sourceKind, _, _ := unstructured.NestedString(hr.Object, "spec", "chart", "spec", "sourceRef", "kind")
+			sourceName, _, _ := unstructured.NestedString(hr.Object, "spec", "chart", "spec", "sourceRef", "name")
+			sourceNamespace, _, _ := unstructured.NestedString(hr.Object, "spec", "chart", "spec", "sourceRef", "namespace")
+			if sourceNamespace == "" {
+				sourceNamespace = hr.GetNamespace()
+			}
+			if sourceKind == fluxHelmRepoKind.Kind {
+				source, err := h.getObject(fluxHelmRepoKind, sourceNamespace, sourceName)
+				if err != nil {
+					klog.V(3).Infof("could not get HelmRepository %s/%s for HelmRelease %s/%s: %v", sourceNamespace, sourceName, hr.GetNamespace(), hr.GetName(), err)
+				} else {
+					repoURL, _, _ := unstructured.NestedString(source.Object, "spec", "url")
+					r.RepoURL = strings.TrimSuffix(repoURL, "/")
+					if IsOCIRepo(r.RepoURL) {
+						// oci helm repositories hold one chart per path
+						r.RepoURL = r.RepoURL + "/" + chartName
+					}
+				}
+			}
+		} else {
+			refKind, _, _ := unstructured.NestedString(hr.Object, "spec", "chartRef", "kind")
+			refName, _, _ := unstructured.NestedString(hr.Object, "spec", "chartRef", "name")
+			refNamespace, _, _ := unstructured.NestedString(hr.Object, "spec", "chartRef", "namespace")
+			if refNamespace == "" {
+				refNamespace = hr.GetNamespace()
+			}
+			if refKind != fluxOCIRepoKind.Kind {
+				klog.V(5).Infof("skipping HelmRelease %s/%s, chart is not sourced from a helm or oci repository", hr.GetNamespace(), hr.GetName())
+				continue
+			}
+			source, err := h.getObject(fluxOCIRepoKind, refNamespace, refName)
+			if err != nil {
+				klog.V(3).Infof("could not get OCIRepository %s/%s for HelmRelease %s/%s: %v", refNamespace, refName, hr.GetNamespace(), hr.GetName(), err)
+				continue
+			}
+			r.RepoURL, _, _ = unstructured.NestedString(source.Object, "spec", "url")
+			r.ChartName = path.Base(r.RepoURL)
+			r.Version, _, _ = unstructured.NestedString(source.Object, "spec", "ref", "tag")
+		}
+		if r.ChartName == "" {
+			continue
+		}
+
+		// The spec version may be a semver range, prefer the version helm-controller actually installed
+		history, _, _ := unstructured.NestedSlice(hr.Object, "status", "history")
+		if len(history) > 0 {
+			if snapshot, ok := history[0].(map[string]any); ok {
+				if v, ok := snapshot["chartVersion"].(string); ok && v != "" {
+					r.Version = v
+				}
+			}
+		} else if v, _, _ := unstructured.NestedString(hr.Object, "status", "lastAttemptedRevision"); v != "" {
+			r.Version = v
+		}
+		releases = append(releases, r)
+	}
+	return releases, nil
+}
+
+func (h *Helm) getArgoReleases(namespace string) ([]GitOpsRelease, error) {
+	items, err := h.listKind(argoApplicationKind, namespace)
+	if err != nil || items == nil {
+		return nil, err
+	}
+	releases := []GitOpsRelease{}
+	for _, app := range items {
+		sources := []any{}
+		multiSource := false
+		if multiple, found, _ := unstructured.NestedSlice(app.Object, "spec", "sources"); found && len(multiple) > 0 {
+			// argo cd ignores spec.source when spec.sources is set
+			sources = multiple
+			multiSource = true
+		} else if source, found, _ := unstructured.NestedMap(app.Object, "spec", "source"); found {
+			sources = append(sources, source)
+		}
+		destination, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "namespace")
+		if destination == "" {
+			destination = app.GetNamespace()
+		}
+		for i, s := range sources {
+			source, ok := s.(map[string]any)
+			if !ok {
+				continue
+			}
+			chartName, _, _ := unstructured.NestedString(source, "chart")
+			if chartName == "" {
+				continue
+			}
+			repoURL, _, _ := unstructured.NestedString(source, "repoURL")
+			repoURL = strings.TrimSuffix(repoURL, "/")
+			if !strings.Contains(repoURL, "://") {
+				// argo cd omits the scheme for oci repositories, which hold one chart per path
+				repoURL = ociScheme + repoURL + "/" + chartName
+			}
+			// The target revision may be a semver range, prefer the version argo cd actually synced
+			version := argoSyncedRevision(app, i, multiSource)
+			if version == "" {
+				targetRevision, _, _ := unstructured.NestedString(source, "targetRevision")
+				if _, err := versions.Parse(targetRevision); err == nil {
+					version = targetRevision
+				} else {
+					klog.V(3).Infof("could not find the synced version of chart %s in Application %s/%s, target revision %q is not a version", chartName, app.GetNamespace(), app.GetName(), targetRevision)
+				}
+			}
+			releaseName, _, _ := unstructured.NestedString(source, "helm", "releaseName")
+			if releaseName == "" {
+				releaseName = app.GetName()
+			}
+			releases = append(releases, GitOpsRelease{
+				Owner: output.GitOpsOwner{
+					Kind:      argoApplicationKind.Kind,
+					Name:      app.GetName(),
+					Namespace: app.GetNamespace(),
+				},
+				ReleaseName: releaseName,
+				Namespace:   destination,
+				ChartName:   chartName,
+				Version:     version,
+				RepoURL:     repoURL,
+			})
+		}
+	}
+	return releases, nil
+}
+
+// argoSyncedRevision returns the revision argo cd synced for the source at index, from status.sync or else from the
+// latest status.history entry. Multi-source applications keep one revision per source in a revisions list.
+func argoSyncedRevision(app unstructured.Unstructured, index int, multiSource bool) string {
+	revision := func(status map[string]any) string {
+		if !multiSource {
+			v, _, _ := unstructured.NestedString(status, "revision")
+			return v
+		}
+		revisions, _, _ := unstructured.NestedStringSlice(status, "revisions")
+		if index < len(revisions) {
+			return revisions[index]
+		}
+		return ""
+	}
+	if sync, found, _ := unstructured.NestedMap(app.Object, "status", "sync"); found {
+		if v := revision(sync); v != "" {
+			return v
+		}
+	}
+	history, _, _ := unstructured.NestedSlice(app.Object, "status", "history")
+	if len(history) > 0 {
+		// argo cd appends to the history, so the latest sync is last
+		if latest, ok := history[len(history)-1].(map[string]any); ok {
+			return revision(latest)
+		}
+	}
+	return ""
+}
+
+// listKind lists all objects of a kind, returning nil if the kind is not installed in the cluster
+func (h *Helm) listKind(kind schema.GroupKind, namespace string) ([]unstructured.Unstructured, error) {
+	mapping, err := h.Kube.RESTMapper.RESTMapping(kind)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			klog.V(5).Infof("%s is not installed in the cluster", kind.String())
+			return nil, nil
+		}
+		return nil, err
+	}
+	list, err := h.Kube.DynamicClient.Resource(mapping.Resource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (h *Helm) getObject(kind schema.GroupKind, namespace, name string) (*unstructured.Unstructured, error) {
+	mapping, err := h.Kube.RESTMapper.RESTMapping(kind)
+	if err != nil {
+		return nil, err
+	}
+	return h.Kube.DynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+func isHTTPRepoURL(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fairwindsops/nova/pkg/kube"
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newGitOpsTestHelm(objects ...runtime.Object) *Helm {
+	fluxHR := schema.GroupVersionKind{Group: "helm.toolkit.fluxcd.io", Version: "v2", Kind: "HelmRelease"}
+	fluxRepo := schema.GroupVersionKind{Group: "source.toolkit.fluxcd.io", Version: "v1", Kind: "HelmRepository"}
+	argoApp := schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Application"}
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{fluxHR.GroupVersion(), fluxRepo.GroupVersion(), argoApp.GroupVersion()})
+	mapper.Add(fluxHR, meta.RESTScopeNamespace)
+	mapper.Add(fluxRepo, meta.RESTScopeNamespace)
+	mapper.Add(argoApp, meta.RESTScopeNamespace)
+
+	listKinds := map[schema.GroupVersionResource]string{
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2", Resource: "helmreleases"}:       "HelmReleaseList",
+		{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "helmrepositories"}: "HelmRepositoryList",
+		{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}:            "ApplicationList",
+	}
+	return &Helm{
+		Kube: &kube.Connection{
+			DynamicClient: dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...),
+			RESTMapper:    mapper,
+		},
+	}
+}
+
+func TestHelm_GetGitOpsReleases(t *testing.T) {
+	helmRelease := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "helm.toolkit.fluxcd.io/v2",
+		"kind":       "HelmRelease",
+		"metadata":   map[string]any{"name": "podinfo", "namespace": "flux-system"},
+		"spec": map[string]any{
+			"targetNamespace": "apps",
+			"chart": map[string]any{
+				"spec": map[string]any{
+					"chart":     "podinfo",
+					"version":   ">=6.0.0",
+					"sourceRef": map[string]any{"kind": "HelmRepository", "name": "podinfo"},
+				},
+			},
+		},
+		"status": map[string]any{
+			"history": []any{
+				map[string]any{"chartVersion": "6.1.0"},
+			},
+		},
+	}}
+	helmRepository := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "source.toolkit.fluxcd.io/v1",
+		"kind":       "HelmRepository",
+		"metadata":   map[string]any{"name": "podinfo", "namespace": "flux-system"},
+		"spec":       map[string]any{"url": "https://stefanprodan.github.io/podinfo/"},
+	}}
+	application := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]any{"name": "redis", "namespace": "argocd"},
+		"spec": map[string]any{
+			"destination": map[string]any{"namespace": "cache"},
+			"sources": []any{
+				map[string]any{"repoURL": "https://github.com/example/values.git", "path": "redis"},
+				map[string]any{"repoURL": "registry-1.docker.io/bitnamicharts", "chart": "redis", "targetRevision": "18.0.0"},
+			},
+		},
+	}}
+
+	h := newGitOpsTestHelm(helmRelease, helmRepository, application)
+	got, err := h.GetGitOpsReleases("")
+	assert.NoError(t, err)
+	assert.Equal(t, []GitOpsRelease{
+		{
+			Owner:       output.GitOpsOwner{Kind: "HelmRelease", Name: "podinfo", Namespace: "flux-system"},
+			ReleaseName: "apps-podinfo",
+			Namespace:   "apps",
+			ChartName:   "podinfo",
+			Version:     "6.1.0",
+			RepoURL:     "https://stefanprodan.github.io/podinfo",
+		},
+		{
+			Owner:       output.GitOpsOwner{Kind: "Application", Name: "redis", Namespace: "argocd"},
+			ReleaseName: "redis",
+			Namespace:   "cache",
+			ChartName:   "redis",
+			Version:     "18.0.0",
+			RepoURL:     "oci://registry-1.docker.io/bitnamicharts/redis",
+		},
+	}, got)
+}
+
+func TestHelm_GetGitOpsReleases_ArgoRevisionRange(t *testing.T) {
+	newApplication := func(name string, source map[string]any, status map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Application",
+			"metadata":   map[string]any{"name": name, "namespace": "argocd"},
+			"spec": map[string]any{
+				"destination": map[string]any{"namespace": "apps"},
+				"source":      source,
+			},
+			"status": status,
+		}}
+	}
+	redis := map[string]any{"repoURL": "https://charts.bitnami.com/bitnami", "chart": "redis", "targetRevision": "18.*"}
+	synced := newApplication("synced", redis, map[string]any{
+		"sync": map[string]any{"revision": "18.2.1"},
+	})
+	history := newApplication("history", redis, map[string]any{
+		"history": []any{
+			map[string]any{"revision": "18.0.0"},
+			map[string]any{"revision": "18.1.0"},
+		},
+	})
+	unsynced := newApplication("unsynced", map[string]any{"repoURL": "https://charts.bitnami.com/bitnami", "chart": "redis", "targetRevision": "^18.0.0"}, map[string]any{})
+
+	h := newGitOpsTestHelm(synced, history, unsynced)
+	got, err := h.GetGitOpsReleases("")
+	assert.NoError(t, err)
+	installed := map[string]string{}
+	for _, r := range got {
+		installed[r.ReleaseName] = r.Version
+	}
+	assert.Equal(t, map[string]string{"synced": "18.2.1", "history": "18.1.0", "unsynced": ""}, installed)
+}
+
+func TestHelm_GetGitOpsReleases_NotInstalled(t *testing.T) {
+	h := &Helm{
+		Kube: &kube.Connection{
+			DynamicClient: dfake.NewSimpleDynamicClient(runtime.NewScheme()),
+			RESTMapper:    meta.NewDefaultRESTMapper(nil),
+		},
+	}
+	got, err := h.GetGitOpsReleases("")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestHelm_GetGitOpsReleasesVersion_RepoErrors(t *testing.T) {
+	// the server fails with an internal error only when the configured credentials are sent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	h := &Helm{}
+	releases := []GitOpsRelease{
+		{ReleaseName: "app", Namespace: "default", ChartName: "app", Version: "1.0.0", RepoURL: server.URL},
+	}
+	got, repoErrors := h.GetGitOpsReleasesVersion(releases, nil, []RepoConfig{{URL: server.URL, Username: "user", Password: "pass"}}, nil)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "", got[0].Latest.Version)
+	if assert.Len(t, repoErrors, 1) {
+		assert.Equal(t, server.URL, repoErrors[0].URL)
+		assert.Equal(t, http.StatusInternalServerError, repoErrors[0].StatusCode)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultHubName is the name of the public ArtifactHub when no hubs are configured
+const DefaultHubName = "artifacthub"
+
+// HubConfig is the connection configuration for ArtifactHub or a self-hosted ArtifactHub instance
+type HubConfig struct {
+	// Name identifies the hub in logs and in the match details of the output
+	Name string `mapstructure:"name"`
+	// URL is the root of the hub, e.g. https://artifacthub.io
+	URL string `mapstructure:"url"`
+	// APIKeyID and APIKeySecret are sent in the X-API-KEY-ID and X-API-KEY-SECRET headers.
+	// APIKeySecretEnv reads the secret from an environment variable instead.
+	APIKeyID           string `mapstructure:"api-key-id"`
+	APIKeySecret       string `mapstructure:"api-key-secret"`
+	APIKeySecretEnv    string `mapstructure:"api-key-secret-env"`
+	CAFile             string `mapstructure:"ca-file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure-skip-verify"`
+	// Timeout limits every request to the hub, and failed requests are retried up to MaxRetries times, waiting at most
+	// MaxRetryWait when the hub asks to wait with Retry-After. They are set from the command line for every hub.
+	Timeout      time.Duration `mapstructure:"-"`
+	MaxRetries   int           `mapstructure:"-"`
+	MaxRetryWait time.Duration `mapstructure:"-"`
+}
+
+// DefaultHub returns the config of the public ArtifactHub
+func DefaultHub() HubConfig {
+	return HubConfig{
+		Name:         DefaultHubName,
+		URL:          artifactHubAPIRoot,
+		Timeout:      DefaultArtifactHubTimeout,
+		MaxRetries:   DefaultArtifactHubMaxRetries,
+		MaxRetryWait: DefaultArtifactHubMaxRetryWait,
+	}
+}
+
+// Validate returns an error if the hub has no url or an incomplete api key
+func (c HubConfig) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("hub %s has no url", c.Name)
+	}
+	if c.APIKeyID == "" && (c.APIKeySecret != "" || c.APIKeySecretEnv != "") {
+		return fmt.Errorf("hub %s has an api key secret but no api-key-id", c.Name)
+	}
+	return nil
+}
+
+// authorize adds the api key headers to a request, if an api key is configured
+func (c HubConfig) authorize(r *http.Request) error {
+	if c.APIKeyID == "" {
+		return nil
+	}
+	secret := c.APIKeySecret
+	if c.APIKeySecretEnv != "" {
+		secret = os.Getenv(c.APIKeySecretEnv)
+		if secret == "" {
+			return fmt.Errorf("could not read api key secret for hub %s: environment variable %s is empty", c.Name, c.APIKeySecretEnv)
+		}
+	}
+	r.Header.Set("X-API-KEY-ID", c.APIKeyID)
+	r.Header.Set("X-API-KEY-SECRET", secret)
+	return nil
+}
+
+// httpClient returns an http client that trusts the CA bundle of the hub, and retries failed requests while
+// sharing the artifacthub rate limit with all other clients
+func (c HubConfig) httpClient() (*http.Client, error) {
+	transport, err := RepoConfig{URL: c.URL, CAFile: c.CAFile, InsecureSkipVerify: c.InsecureSkipVerify}.transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &retryTransport{
+		base:         transport,
+		limiter:      artifactHubLimiter,
+		timeout:      c.Timeout,
+		maxRetries:   c.MaxRetries,
+		maxRetryWait: c.MaxRetryWait,
+		backoff:      initialRetryBackoff,
+	}}, nil
+}
+
+// rootURL returns the url of the hub without a trailing slash
+func (c HubConfig) rootURL() string {
+	return strings.TrimSuffix(c.URL, "/")
+}
+
+// HubPackageList is the raw package list of a hub
+type HubPackageList struct {
+	Hub  string
+	Data []byte
+}
+
+// MergePackageLists combines the raw package lists of several hubs into one, recording the hub of every package.
+// A package listed by more than one hub is only kept from the first hub that lists it.
+func MergePackageLists(lists []HubPackageList) ([]byte, error) {
+	merged := ArtifactHubCachedPackagesList{}
+	seen := map[string]bool{}
+	for _, list := range lists {
+		packages := ArtifactHubCachedPackagesList{}
+		if err := json.Unmarshal(list.Data, &packages); err != nil {
+			return nil, fmt.Errorf("invalid package list from hub %s: %v", list.Hub, err)
+		}
+		for _, p := range packages {
+			key := p.Repository.URL + "/" + p.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if p.Hub == "" {
+				p.Hub = list.Hub
+			}
+			merged = append(merged, p)
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// MergePackages combines the packages of several hubs. A package listed by more than one hub is only kept from
+// the first hub that lists it.
+func MergePackages(lists ...[]ArtifactHubHelmPackage) []ArtifactHubHelmPackage {
+	merged := []ArtifactHubHelmPackage{}
+	seen := map[string]bool{}
+	for _, packages := range lists {
+		for _, p := range packages {
+			key := p.Repository.URL + "/" + p.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactHubCachedPackageClientForHub(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nova" || r.Header.Get("X-API-KEY-ID") != "key-id" || r.Header.Get("X-API-KEY-SECRET") != "key-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"name": "internal-chart", "latest_version": "1.0.0", "repository": {"name": "internal", "url": "https://charts.example.com"}}]`))
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	assert.NoError(t, err)
+	t.Setenv("NOVA_TEST_HUB_SECRET", "key-secret")
+
+	hub := HubConfig{Name: "internal", URL: server.URL + "/", APIKeyID: "key-id", APIKeySecretEnv: "NOVA_TEST_HUB_SECRET", CAFile: caFile}
+	assert.NoError(t, hub.Validate())
+	client, err := NewArtifactHubCachedPackageClientForHub("", hub)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/api/v1/nova", client.APIRoot)
+	assert.Equal(t, "", client.CacheFile)
+
+	packages, err := client.List()
+	assert.NoError(t, err)
+	assert.Len(t, packages, 1)
+	assert.Equal(t, "internal-chart", packages[0].Name)
+	assert.Equal(t, "internal", packages[0].Hub)
+
+	hub.APIKeySecretEnv = "NOVA_TEST_HUB_SECRET_UNSET"
+	client, err = NewArtifactHubCachedPackageClientForHub("", hub)
+	assert.NoError(t, err)
+	_, err = client.List()
+	assert.Error(t, err)
+
+	hub.CAFile = ""
+	client, err = NewArtifactHubCachedPackageClientForHub("", hub)
+	assert.NoError(t, err)
+	_, err = client.FetchPackageList()
+	assert.Error(t, err, "the server certificate is not trusted without the CA file")
+}
+
+func TestHubConfig_Validate(t *testing.T) {
+	assert.NoError(t, DefaultHub().Validate())
+	assert.Error(t, HubConfig{Name: "no-url"}.Validate())
+	assert.Error(t, HubConfig{Name: "no-key-id", URL: "https://hub.example.com", APIKeySecret: "secret"}.Validate())
+}
+
+func TestMergePackageLists(t *testing.T) {
+	internal := []byte(`[{"name": "redis", "repository": {"name": "mirror", "url": "https://charts.bitnami.com/bitnami"}}, {"name": "internal-chart", "repository": {"name": "internal", "url": "https://charts.example.com"}}]`)
+	public := []byte(`[{"name": "redis", "repository": {"name": "bitnami", "url": "https://charts.bitnami.com/bitnami"}}, {"name": "redis", "repository": {"name": "other", "url": "https://other.example.com"}}]`)
+	data, err := MergePackageLists([]HubPackageList{{Hub: "internal", Data: internal}, {Hub: DefaultHubName, Data: public}})
+	assert.NoError(t, err)
+
+	packages, err := ParsePackageList(data)
+	assert.NoError(t, err)
+	var got []string
+	for _, p := range packages {
+		got = append(got, p.Hub+"/"+p.Repository.Name+"/"+p.Name)
+	}
+	assert.Equal(t, []string{"internal/mirror/redis", "internal/internal/internal-chart", "artifacthub/other/redis"}, got)
+	assert.Len(t, MergePackages(packages[:2], packages), 3)
+
+	_, err = MergePackageLists([]HubPackageList{{Hub: "broken", Data: []byte("{")}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/fairwindsops/nova/pkg/versions"
+	"k8s.io/klog/v2"
+)
+
+// ServerVersion returns the Kubernetes version of the cluster without the pre-release and build metadata that
+// managed clusters add to it, e.g. 1.29.3 for v1.29.3-eks-adc7111
+func (h *Helm) ServerVersion() (string, error) {
+	info, err := h.Kube.Client.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("could not get the kubernetes version of the cluster: %v", err)
+	}
+	return NormalizeKubeVersion(info.GitVersion)
+}
+
+// NormalizeKubeVersion returns the major, minor and patch version of a Kubernetes version, e.g. 1.29.0 for v1.29
+// or 1.29.3 for v1.29.3-eks-adc7111
+func NormalizeKubeVersion(kubeVersion string) (string, error) {
+	v, err := versions.Parse(kubeVersion)
+	if err != nil {
+		return "", fmt.Errorf("could not parse kubernetes version %s: %v", kubeVersion, err)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch()), nil
+}
+
+// KubeVersionSatisfied reports whether the kubeVersion constraint of a chart allows a Kubernetes version. Charts
+// without a kubeVersion, or with one that cannot be parsed, are assumed to support every Kubernetes version.
+func KubeVersionSatisfied(constraint, kubeVersion string) bool {
+	if constraint == "" {
+		return true
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		klog.V(2).Infof("ignoring invalid kubeVersion %q: %v", constraint, err)
+		return true
+	}
+	v, err := versions.Parse(kubeVersion)
+	if err != nil {
+		return true
+	}
+	return c.Check(v)
+}
+
+// SetKubeCompatibility checks whether the latest version of a release supports a Kubernetes version. If it does
+// not, the newest available version that does, is newer than the installed version and satisfies the desired
+// version constraint of the release if any, is stored in LatestCompatibleVersion.
+func SetKubeCompatibility(rls *output.ReleaseOutput, kubeVersion string) {
+	rls.KubeIncompatible = false
+	rls.LatestCompatibleVersion = ""
+	if kubeVersion == "" || rls.Latest.Version == "" || KubeVersionSatisfied(rls.Latest.KubeVersion, kubeVersion) {
+		return
+	}
+	rls.KubeIncompatible = true
+	var desired *semver.Constraints
+	if rls.DesiredVersion != "" {
+		desired, _ = semver.NewConstraint(rls.DesiredVersion)
+	}
+	// available versions are sorted newest first
+	for _, available := range rls.AvailableVersions {
+		if !versions.Newer(available.Version, rls.Installed.Version) {
+			// the remaining versions are older still, and would be downgrades
+			return
+		}
+		if !KubeVersionSatisfied(available.KubeVersion, kubeVersion) {
+			continue
+		}
+		if desired != nil {
+			v, err := versions.Parse(available.Version)
+			if err != nil || !desired.Check(v) {
+				continue
+			}
+		}
+		rls.LatestCompatibleVersion = available.Version
+		return
+	}
+}
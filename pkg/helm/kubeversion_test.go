@@ -0,0 +1,112 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/fairwindsops/nova/pkg/kube"
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHelm_ServerVersion(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.29.3-eks-adc7111"}
+	h := &Helm{Kube: &kube.Connection{Client: client}}
+
+	got, err := h.ServerVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.29.3", got)
+}
+
+func TestKubeVersionSatisfied(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       bool
+	}{
+		{"", true},
+		{">=1.25.0-0", true},
+		{">= 1.19, < 1.29", false},
+		{"^1.30", false},
+		{"not a constraint", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			assert.Equal(t, tt.want, KubeVersionSatisfied(tt.constraint, "1.29.3"))
+		})
+	}
+}
+
+func TestSetKubeCompatibility(t *testing.T) {
+	available := []output.VersionInfo{
+		{Version: "3.0.0", KubeVersion: ">=1.30.0-0"},
+		{Version: "2.1.0", KubeVersion: ">=1.27.0-0"},
+		{Version: "1.5.0", KubeVersion: ">=1.20.0-0"},
+	}
+	tests := []struct {
+		name             string
+		rls              output.ReleaseOutput
+		kubeVersion      string
+		wantIncompatible bool
+		wantCompatible   string
+	}{
+		{
+			name:        "compatible",
+			rls:         output.ReleaseOutput{Latest: available[1], AvailableVersions: available},
+			kubeVersion: "1.29.3",
+		},
+		{
+			name:             "incompatible",
+			rls:              output.ReleaseOutput{Latest: available[0], AvailableVersions: available},
+			kubeVersion:      "1.29.3",
+			wantIncompatible: true,
+			wantCompatible:   "2.1.0",
+		},
+		{
+			name:             "incompatible with desired version",
+			rls:              output.ReleaseOutput{Latest: available[0], AvailableVersions: available, DesiredVersion: "^1.0.0"},
+			kubeVersion:      "1.29.3",
+			wantIncompatible: true,
+			wantCompatible:   "1.5.0",
+		},
+		{
+			name:             "no compatible upgrade",
+			rls:              output.ReleaseOutput{Installed: output.VersionInfo{Version: "2.1.0"}, Latest: available[0], AvailableVersions: available},
+			kubeVersion:      "1.29.3",
+			wantIncompatible: true,
+		},
+		{
+			name:             "no compatible version",
+			rls:              output.ReleaseOutput{Latest: available[0], AvailableVersions: available},
+			kubeVersion:      "1.19.0",
+			wantIncompatible: true,
+		},
+		{
+			name: "unknown kubernetes version",
+			rls:  output.ReleaseOutput{Latest: available[0], AvailableVersions: available},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetKubeCompatibility(&tt.rls, tt.kubeVersion)
+			assert.Equal(t, tt.wantIncompatible, tt.rls.KubeIncompatible)
+			assert.Equal(t, tt.wantCompatible, tt.rls.LatestCompatibleVersion)
+		})
+	}
+}
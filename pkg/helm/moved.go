@@ -0,0 +1,172 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxSharedURLCharts is how many differently named charts may share a home or source URL for it to still
+	// identify a chart. Generic URLs like https://bitnami.com are shared by hundreds of charts.
+	maxSharedURLCharts = 3
+	// maxMovedCharts limits how many possible new locations are reported for a release
+	maxMovedCharts = 3
+)
+
+// FindMovedCharts looks for artifacthub packages that share a distinctive home or source URL with the installed chart,
+// which is likely when a chart was renamed (e.g. nginx-ingress to ingress-nginx) or republished under the same name
+// in another repository. The package the release was matched to, in matchedRepository, and deprecated packages with
+// the same name are copies of the installed chart and are not reported.
+// Maintainer overlap is reported as an additional reason but is not enough on its own.
+func (m *ArtifactHubMatcher) FindMovedCharts(clusterRelease *release.Release, ahubPackages []ArtifactHubHelmPackage, matchedRepository string) []output.MovedChart {
+	metadata := clusterRelease.Chart.Metadata
+	// releaseURLs maps the urls of the installed chart to whether they are its home url, a source link or both
+	releaseURLs := map[string][]string{}
+	if home := normalizeURL(metadata.Home); home != "" {
+		releaseURLs[home] = append(releaseURLs[home], criterionHomeURL)
+	}
+	for _, source := range metadata.Sources {
+		if source := normalizeURL(source); source != "" && !containsString(releaseURLs[source], criterionSourceLink) {
+			releaseURLs[source] = append(releaseURLs[source], criterionSourceLink)
+		}
+	}
+	if len(releaseURLs) == 0 {
+		return nil
+	}
+
+	type candidate struct {
+		pkg  ArtifactHubHelmPackage
+		urls map[string]bool
+	}
+	var candidates []candidate
+	chartsByURL := map[string]map[string]bool{}
+	for _, p := range ahubPackages {
+		if containsString(m.BlockedRepositories, p.Repository.Name) {
+			continue
+		}
+		shared := map[string]bool{}
+		for _, u := range packageURLs(p) {
+			if _, ok := releaseURLs[u]; !ok {
+				continue
+			}
+			if chartsByURL[u] == nil {
+				chartsByURL[u] = map[string]bool{}
+			}
+			chartsByURL[u][p.Name] = true
+			shared[u] = true
+		}
+		sameChart := p.Name == metadata.Name && (p.Repository.Name == matchedRepository || p.Deprecated)
+		if len(shared) > 0 && !sameChart {
+			candidates = append(candidates, candidate{pkg: p, urls: shared})
+		}
+	}
+
+	maintainers := map[string]bool{}
+	for _, mt := range metadata.Maintainers {
+		if mt != nil && mt.Name != "" {
+			maintainers[mt.Name] = true
+		}
+	}
+
+	type movedChart struct {
+		chart output.MovedChart
+		stars int
+	}
+	var moved []movedChart
+	for _, c := range candidates {
+		reasons := map[string]bool{}
+		for u := range c.urls {
+			if len(chartsByURL[u]) <= maxSharedURLCharts {
+				for _, r := range releaseURLs[u] {
+					reasons[r] = true
+				}
+			}
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		for _, mt := range c.pkg.Maintainers {
+			if maintainers[mt.Name] {
+				reasons[criterionMaintainers] = true
+				break
+			}
+		}
+		sortedReasons := make([]string, 0, len(reasons))
+		for r := range reasons {
+			sortedReasons = append(sortedReasons, r)
+		}
+		sort.Strings(sortedReasons)
+		moved = append(moved, movedChart{
+			chart: output.MovedChart{
+				Repository: c.pkg.Repository.Name,
+				ChartName:  c.pkg.Name,
+				Version:    c.pkg.Version,
+				Reasons:    sortedReasons,
+			},
+			stars: c.pkg.Stars,
+		})
+	}
+
+	sort.SliceStable(moved, func(i, j int) bool {
+		if len(moved[i].chart.Reasons) != len(moved[j].chart.Reasons) {
+			return len(moved[i].chart.Reasons) > len(moved[j].chart.Reasons)
+		}
+		return moved[i].stars > moved[j].stars
+	})
+	var ret []output.MovedChart
+	for _, mc := range moved {
+		if len(ret) == maxMovedCharts {
+			break
+		}
+		ret = append(ret, mc.chart)
+	}
+	if len(ret) > 0 {
+		klog.V(5).Infof("chart %s of release %s/%s has possibly moved to %s/%s", metadata.Name, clusterRelease.Namespace, clusterRelease.Name, ret[0].Repository, ret[0].ChartName)
+	}
+	return ret
+}
+
+// packageURLs returns the normalized home URL and source links of a package
+func packageURLs(p ArtifactHubHelmPackage) []string {
+	var ret []string
+	if home := normalizeURL(p.HomeURL); home != "" {
+		ret = append(ret, home)
+	}
+	for _, link := range p.Links {
+		if link.Name == "source" {
+			if source := normalizeURL(link.URL); source != "" {
+				ret = append(ret, source)
+			}
+		}
+	}
+	return ret
+}
+
+// normalizeURL makes URLs comparable regardless of scheme, case, trailing slashes and .git suffixes
+func normalizeURL(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	u = strings.TrimPrefix(u, "https://")
+	u = strings.TrimPrefix(u, "http://")
+	u = strings.TrimPrefix(u, "www.")
+	u = strings.TrimSuffix(u, "/")
+	u = strings.TrimSuffix(u, ".git")
+	return u
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestArtifactHubMatcher_FindMovedCharts(t *testing.T) {
+	nginxIngress := &release.Release{
+		Name:      "ingress",
+		Namespace: "ingress",
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:        "nginx-ingress",
+				Version:     "1.41.3",
+				Home:        "https://github.com/kubernetes/ingress-nginx",
+				Sources:     []string{"https://github.com/kubernetes/ingress-nginx.git"},
+				Maintainers: []*chart.Maintainer{{Name: "rikatz"}},
+			},
+		},
+	}
+	ingressNginx := ArtifactHubHelmPackage{
+		Name:        "ingress-nginx",
+		Version:     "4.10.0",
+		HomeURL:     "https://github.com/kubernetes/ingress-nginx",
+		Links:       []Link{{Name: "source", URL: "https://github.com/kubernetes/ingress-nginx"}},
+		Maintainers: []Maintainer{{Name: "rikatz"}},
+		Repository:  ArtifactHubRepository{Name: "ingress-nginx"},
+	}
+	fork := ArtifactHubHelmPackage{
+		Name:       "ingress-nginx-fork",
+		Version:    "0.1.0",
+		Links:      []Link{{Name: "source", URL: "https://github.com/kubernetes/ingress-nginx/"}},
+		Repository: ArtifactHubRepository{Name: "someone"},
+	}
+	packages := []ArtifactHubHelmPackage{fork, ingressNginx}
+
+	got := FindBestArtifactHubMatch(nginxIngress, packages)
+	assert.Equal(t, "", got.Latest.Version)
+	assert.Equal(t, []output.MovedChart{
+		{Repository: "ingress-nginx", ChartName: "ingress-nginx", Version: "4.10.0", Reasons: []string{"home-url", "maintainers", "source-link"}},
+		{Repository: "someone", ChartName: "ingress-nginx-fork", Version: "0.1.0", Reasons: []string{"home-url", "source-link"}},
+	}, got.PossiblyMovedTo)
+
+	blocked := &ArtifactHubMatcher{BlockedRepositories: []string{"someone"}}
+	assert.Len(t, blocked.FindMovedCharts(nginxIngress, packages, ""), 1)
+
+	deprecated := ArtifactHubHelmPackage{Name: "nginx-ingress", Version: "1.41.3", Deprecated: true, Repository: ArtifactHubRepository{Name: "stable"}}
+	got = FindBestArtifactHubMatch(nginxIngress, append(packages, deprecated))
+	assert.Equal(t, "1.41.3", got.Latest.Version)
+	assert.Len(t, got.PossiblyMovedTo, 2, "deprecated charts should also get suggestions")
+
+	current := ArtifactHubHelmPackage{Name: "nginx-ingress", Version: "1.41.3", Repository: ArtifactHubRepository{Name: "stable"}}
+	got = FindBestArtifactHubMatch(nginxIngress, append(packages, current))
+	assert.Empty(t, got.PossiblyMovedTo, "charts that were found should not get suggestions")
+}
+
+func TestArtifactHubMatcher_FindMovedCharts_SameName(t *testing.T) {
+	minio := &release.Release{
+		Name:      "minio",
+		Namespace: "storage",
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "minio",
+				Version: "12.0.0",
+				Sources: []string{"https://github.com/minio/minio"},
+			},
+		},
+	}
+	deprecated := ArtifactHubHelmPackage{
+		Name:       "minio",
+		Version:    "12.0.0",
+		Deprecated: true,
+		Links:      []Link{{Name: "source", URL: "https://github.com/minio/minio"}},
+		Repository: ArtifactHubRepository{Name: "bitnami"},
+	}
+	mirror := deprecated
+	mirror.Repository = ArtifactHubRepository{Name: "mirror"}
+	vendor := ArtifactHubHelmPackage{
+		Name:       "minio",
+		Version:    "5.2.0",
+		Links:      []Link{{Name: "source", URL: "https://github.com/minio/minio/"}},
+		Repository: ArtifactHubRepository{Name: "minio"},
+	}
+
+	got := (&ArtifactHubMatcher{}).FindMovedCharts(minio, []ArtifactHubHelmPackage{deprecated, mirror, vendor}, "bitnami")
+	assert.Equal(t, []output.MovedChart{
+		{Repository: "minio", ChartName: "minio", Version: "5.2.0", Reasons: []string{"source-link"}},
+	}, got)
+	assert.Empty(t, (&ArtifactHubMatcher{}).FindMovedCharts(minio, []ArtifactHubHelmPackage{deprecated, vendor}, "minio"))
+}
+
+func TestArtifactHubMatcher_FindMovedCharts_GenericURL(t *testing.T) {
+	redis := &release.Release{
+		Name: "redis",
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{Name: "redis", Home: "https://bitnami.com"},
+		},
+	}
+	var packages []ArtifactHubHelmPackage
+	for i := 0; i <= maxSharedURLCharts; i++ {
+		packages = append(packages, ArtifactHubHelmPackage{
+			Name:       fmt.Sprintf("chart-%d", i),
+			HomeURL:    "https://bitnami.com/",
+			Repository: ArtifactHubRepository{Name: "bitnami"},
+		})
+	}
+	assert.Empty(t, (&ArtifactHubMatcher{}).FindMovedCharts(redis, packages, ""), "urls shared by many charts should be ignored")
+	assert.Len(t, (&ArtifactHubMatcher{}).FindMovedCharts(redis, packages[:maxSharedURLCharts], ""), maxSharedURLCharts)
+}
+
+func Test_normalizeURL(t *testing.T) {
+	assert.Equal(t, "github.com/org/repo", normalizeURL("https://www.GitHub.com/org/repo.git/"))
+	assert.Equal(t, "", normalizeURL(" "))
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/fairwindsops/nova/pkg/versions"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/klog/v2"
+)
+
+const ociScheme = "oci://"
+
+// IsOCIRepo returns true if the repository URL points to an OCI registry
+func IsOCIRepo(url string) bool {
+	return strings.HasPrefix(url, ociScheme)
+}
+
+// ociSource is the registry repository an OCI chart repo was listed from, used to fetch chart metadata on demand
+type ociSource struct {
+	repo    name.Repository
+	options []remote.Option
+	mutex   sync.Mutex
+}
+
+// loadOCIReleases lists the tags of a chart stored in an OCI registry, e.g. oci://ghcr.io/org/charts/mychart, so that the
+// repo can be used like an index.yaml based repo. Fetching the metadata of a chart version takes a manifest and a blob
+// request, so to stay within registry rate limits it is only fetched for the versions that are looked up, like the
+// installed and the newest version. The other versions only have a name and version.
+func (r *Repo) loadOCIReleases() error {
+	repo, err := name.NewRepository(strings.TrimPrefix(r.URL, ociScheme))
+	if err != nil {
+		return err
+	}
+	options, err := r.Config.ociOptions()
+	if err != nil {
+		return err
+	}
+	tags, err := remote.List(repo, options...)
+	if err != nil {
+		return err
+	}
+	chartName := path.Base(repo.RepositoryStr())
+	klog.V(8).Infof("found %d tags for oci chart %s", len(tags), r.URL)
+
+	releases := make([]ChartRelease, 0, len(tags))
+	for _, tag := range tags {
+		chartVersion := ociTagToVersion(tag)
+		// pre-releases are kept so that installed pre-releases are found, and left out by NewestChartVersion
+		if _, err := versions.Parse(chartVersion); err != nil {
+			continue
+		}
+		releases = append(releases, ChartRelease{Name: chartName, Version: chartVersion, ociTag: tag})
+	}
+	r.oci = &ociSource{repo: repo, options: options}
+	r.Charts.Entries = map[string][]ChartRelease{chartName: releases}
+	return nil
+}
+
+// chartRelease returns the i-th release of a chart, fetching its metadata first if the repo is an OCI registry
+// and it was not fetched yet
+func (r *Repo) chartRelease(chartName string, i int) ChartRelease {
+	release := r.Charts.Entries[chartName][i]
+	if r.oci == nil || release.ociTag == "" {
+		return release
+	}
+	r.oci.mutex.Lock()
+	defer r.oci.mutex.Unlock()
+	release = r.Charts.Entries[chartName][i]
+	if release.ociTag == "" {
+		return release
+	}
+	metadata, err := fetchOCIChartMetadata(r.oci.repo.Tag(release.ociTag), r.oci.options)
+	if err != nil {
+		klog.V(5).Infof("could not get chart metadata for %s:%s: %v", r.URL, release.ociTag, err)
+		release.ociTag = ""
+	} else {
+		release = chartReleaseFromMetadata(metadata)
+	}
+	r.Charts.Entries[chartName][i] = release
+	return release
+}
+
+// fetchOCIChartMetadata reads the helm chart config blob, which contains the Chart.yaml metadata as json
+func fetchOCIChartMetadata(ref name.Reference, options []remote.Option) (*chart.Metadata, error) {
+	img, err := remote.Image(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	config, err := img.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	metadata := &chart.Metadata{}
+	err = json.Unmarshal(config, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func chartReleaseFromMetadata(metadata *chart.Metadata) ChartRelease {
+	maintainers := make([]chart.Maintainer, 0, len(metadata.Maintainers))
+	for _, m := range metadata.Maintainers {
+		if m != nil {
+			maintainers = append(maintainers, *m)
+		}
+	}
+	return ChartRelease{
+		APIVersion:  metadata.APIVersion,
+		AppVersion:  metadata.AppVersion,
+		KubeVersion: metadata.KubeVersion,
+		Description: metadata.Description,
+		Maintainers: maintainers,
+		Name:        metadata.Name,
+		Version:     metadata.Version,
+		Home:        metadata.Home,
+		Sources:     metadata.Sources,
+		Keywords:    metadata.Keywords,
+		Icon:        metadata.Icon,
+		Deprecated:  metadata.Deprecated,
+	}
+}
+
+// ociTagToVersion reverses the conversion helm does when pushing charts, since "+" is not allowed in OCI tags
+func ociTagToVersion(tag string) string {
+	return strings.ReplaceAll(tag, "_", "+")
+}
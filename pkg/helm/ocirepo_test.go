@@ -0,0 +1,119 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+type rawManifest []byte
+
+func (m rawManifest) RawManifest() ([]byte, error) { return m, nil }
+
+// pushTestChart pushes a manifest with a helm chart config blob, which is all nova needs to read chart metadata
+func pushTestChart(t *testing.T, repo name.Repository, metadata chart.Metadata) {
+	config, err := json.Marshal(metadata)
+	assert.NoError(t, err)
+	configLayer := static.NewLayer(config, "application/vnd.cncf.helm.config.v1+json")
+	assert.NoError(t, remote.WriteLayer(repo, configLayer))
+	digest, err := configLayer.Digest()
+	assert.NoError(t, err)
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":"application/vnd.cncf.helm.config.v1+json","digest":%q,"size":%d},"layers":[]}`,
+		types.OCIManifestSchema1, digest.String(), len(config))
+	tag := strings.ReplaceAll(metadata.Version, "+", "_")
+	assert.NoError(t, remote.Put(repo.Tag(tag), rawManifest(manifest)))
+}
+
+func TestRepo_loadOCIReleases(t *testing.T) {
+	// count the manifest reads, which are needed to fetch chart metadata
+	var manifestReads atomic.Int32
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") {
+			manifestReads.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	repo, err := name.NewRepository(host+"/charts/test", name.Insecure)
+	assert.NoError(t, err)
+
+	for _, v := range []string{"1.0.0", "1.0.1", "1.1.0", "2.0.0-rc1"} {
+		pushTestChart(t, repo, chart.Metadata{
+			Name:        "test",
+			Version:     v,
+			Home:        "https://example.com/charts",
+			Description: "This is a chart.",
+			Sources:     []string{"https://example.com/charts"},
+			Maintainers: []*chart.Maintainer{{Name: "John"}},
+		})
+	}
+
+	r := &Repo{URL: "oci://" + host + "/charts/test", Charts: &ChartReleases{}}
+	assert.NoError(t, r.loadReleases())
+	assert.Len(t, r.Charts.Entries["test"], 4)
+	manifestReads.Store(0)
+
+	installed := &release.Release{Chart: &chart.Chart{Metadata: &chart.Metadata{
+		Name:        "test",
+		Version:     "1.0.0",
+		Home:        "https://example.com/charts",
+		Description: "This is a chart.",
+		Sources:     []string{"https://example.com/charts"},
+		Maintainers: []*chart.Maintainer{{Name: "John"}},
+	}}}
+	newest := TryToFindNewestReleaseByChart(installed, []*Repo{r}, false)
+	if assert.NotNil(t, newest) {
+		assert.Equal(t, "1.1.0", newest.Version)
+		assert.Equal(t, "This is a chart.", newest.Description)
+	}
+	// only the installed and the newest version are fetched, once each
+	TryToFindNewestReleaseByChart(installed, []*Repo{r}, false)
+	assert.Equal(t, int32(2), manifestReads.Load())
+	newest = TryToFindNewestReleaseByChart(installed, []*Repo{r}, true)
+	if assert.NotNil(t, newest) {
+		assert.Equal(t, "2.0.0-rc1", newest.Version)
+	}
+
+	// a release running a pre-release is matched to the repo
+	installed.Chart.Metadata.Version = "2.0.0-rc1"
+	newest = TryToFindNewestReleaseByChart(installed, []*Repo{r}, false)
+	if assert.NotNil(t, newest) {
+		assert.Equal(t, "1.1.0", newest.Version)
+	}
+}
+
+func Test_ociTagToVersion(t *testing.T) {
+	assert.Equal(t, "1.0.0+build.1", ociTagToVersion("1.0.0_build.1"))
+	assert.Equal(t, "1.0.0", ociTagToVersion("1.0.0"))
+}
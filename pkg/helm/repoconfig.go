@@ -0,0 +1,286 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"gopkg.in/yaml.v2"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"k8s.io/klog/v2"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// RepoConfig is the connection configuration for a single chart repository
+type RepoConfig struct {
+	// Name is the name of the repository in helm's repositories.yaml, used to find its cached index file
+	Name     string `mapstructure:"name"`
+	URL      string `mapstructure:"url"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Token is sent as a bearer token. TokenEnv and TokenFile read the token from an environment variable or a file instead.
+	Token              string `mapstructure:"token"`
+	TokenEnv           string `mapstructure:"token-env"`
+	TokenFile          string `mapstructure:"token-file"`
+	CAFile             string `mapstructure:"ca-file"`
+	CertFile           string `mapstructure:"cert-file"`
+	KeyFile            string `mapstructure:"key-file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure-skip-verify"`
+}
+
+// hasCredentials returns true if any authentication or TLS option is set
+func (c RepoConfig) hasCredentials() bool {
+	return c.Username != "" || c.Password != "" || c.Token != "" || c.TokenEnv != "" || c.TokenFile != "" ||
+		c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.InsecureSkipVerify
+}
+
+// token returns the bearer token for the repository, if one is configured
+func (c RepoConfig) token() (string, error) {
+	switch {
+	case c.Token != "":
+		return c.Token, nil
+	case c.TokenEnv != "":
+		token := os.Getenv(c.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is empty", c.TokenEnv)
+		}
+		return token, nil
+	case c.TokenFile != "":
+		data, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", nil
+}
+
+// authorize adds basic auth or bearer token credentials to a request
+func (c RepoConfig) authorize(r *http.Request) error {
+	token, err := c.token()
+	if err != nil {
+		return fmt.Errorf("could not read token for %s: %v", c.URL, err)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	} else if c.Username != "" || c.Password != "" {
+		r.SetBasicAuth(c.Username, c.Password)
+	}
+	return nil
+}
+
+// transport returns an http transport with the custom CA bundle and client certificate of the repository
+func (c RepoConfig) transport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.CAFile == "" && c.CertFile == "" && !c.InsecureSkipVerify {
+		return transport, nil
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify, // #nosec G402 - explicitly requested by the user
+	}
+	if c.CAFile != "" {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle %s: %v", c.CAFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// httpClient returns an http client configured for the repository
+func (c RepoConfig) httpClient() (*http.Client, error) {
+	transport, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// ociOptions returns the registry options for an OCI repository. Credentials from the docker config are used
+// when none are configured.
+func (c RepoConfig) ociOptions() ([]remote.Option, error) {
+	transport, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+	options := []remote.Option{remote.WithTransport(transport)}
+	token, err := c.token()
+	if err != nil {
+		return nil, fmt.Errorf("could not read token for %s: %v", c.URL, err)
+	}
+	switch {
+	case token != "":
+		options = append(options, remote.WithAuth(&authn.Bearer{Token: token}))
+	case c.Username != "" || c.Password != "":
+		options = append(options, remote.WithAuth(&authn.Basic{Username: c.Username, Password: c.Password}))
+	default:
+		options = append(options, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	}
+	return options, nil
+}
+
+// helmRepositoryFile is the subset of helm's repositories.yaml that nova reads
+type helmRepositoryFile struct {
+	Repositories []helmRepositoryEntry `json:"repositories"`
+}
+
+type helmRepositoryEntry struct {
+	Name                  string `json:"name"`
+	URL                   string `json:"url"`
+	Username              string `json:"username"`
+	Password              string `json:"password"`
+	CertFile              string `json:"certFile"`
+	KeyFile               string `json:"keyFile"`
+	CAFile                string `json:"caFile"`
+	InsecureSkipTLSverify bool   `json:"insecure_skip_tls_verify"`
+}
+
+// DefaultHelmRepositoryConfig returns the path of the repositories.yaml file helm uses,
+// honoring the HELM_REPOSITORY_CONFIG environment variable
+func DefaultHelmRepositoryConfig() string {
+	if path, ok := os.LookupEnv("HELM_REPOSITORY_CONFIG"); ok {
+		return path
+	}
+	return helmpath.ConfigPath("repositories.yaml")
+}
+
+// DefaultHelmRepositoryCache returns the directory helm caches repository index files in,
+// honoring the HELM_REPOSITORY_CACHE environment variable
+func DefaultHelmRepositoryCache() string {
+	if path, ok := os.LookupEnv("HELM_REPOSITORY_CACHE"); ok {
+		return path
+	}
+	return helmpath.CachePath("repository")
+}
+
+// LoadHelmRepositoryConfigs reads the repositories from a helm repositories.yaml file
+func LoadHelmRepositoryConfigs(path string) ([]RepoConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load repositories file (%s): %v", path, err)
+	}
+	file := helmRepositoryFile{}
+	if err := sigsyaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("couldn't parse repositories file (%s): %v", path, err)
+	}
+	configs := make([]RepoConfig, 0, len(file.Repositories))
+	for _, entry := range file.Repositories {
+		configs = append(configs, RepoConfig{
+			Name:               entry.Name,
+			URL:                strings.TrimSuffix(entry.URL, "/"),
+			Username:           entry.Username,
+			Password:           entry.Password,
+			CAFile:             entry.CAFile,
+			CertFile:           entry.CertFile,
+			KeyFile:            entry.KeyFile,
+			InsecureSkipVerify: entry.InsecureSkipTLSverify,
+		})
+	}
+	return configs, nil
+}
+
+// ApplyHelmCredentials copies the credentials of matching helm repositories onto repositories that have none configured
+func ApplyHelmCredentials(configs []RepoConfig, helmConfigs []RepoConfig) []RepoConfig {
+	byURL := map[string]RepoConfig{}
+	for _, hc := range helmConfigs {
+		byURL[strings.TrimSuffix(hc.URL, "/")] = hc
+	}
+	ret := make([]RepoConfig, len(configs))
+	for i, c := range configs {
+		ret[i] = c
+		if c.hasCredentials() {
+			continue
+		}
+		if hc, ok := byURL[strings.TrimSuffix(c.URL, "/")]; ok {
+			klog.V(5).Infof("using helm repository credentials for %s", c.URL)
+			hc.URL = c.URL
+			ret[i] = hc
+		}
+	}
+	return ret
+}
+
+// NewReposFromHelmCache builds repos from the index files helm caches when running `helm repo add` or `helm repo update`,
+// so that no network access is needed. A maxAge of zero accepts cached files of any age. The configs of repositories
+// without a usable cached index are returned so that they can be loaded with NewReposFromConfig.
+func NewReposFromHelmCache(configs []RepoConfig, cacheDir string, maxAge time.Duration) ([]*Repo, []RepoConfig) {
+	repos := []*Repo{}
+	uncached := []RepoConfig{}
+	for _, config := range configs {
+		if config.Name == "" || IsOCIRepo(config.URL) {
+			uncached = append(uncached, config)
+			continue
+		}
+		indexFile := filepath.Join(cacheDir, helmpath.CacheIndexFile(config.Name))
+		charts, err := loadCachedIndex(indexFile, maxAge)
+		if err != nil {
+			klog.V(5).Infof("not using cached index for repo %s: %v", config.Name, err)
+			uncached = append(uncached, config)
+			continue
+		}
+		klog.V(8).Infof("loaded chart repository %s from %s", config.URL, indexFile)
+		repos = append(repos, &Repo{
+			URL:    config.URL,
+			Charts: charts,
+			Config: config,
+		})
+	}
+	return repos, uncached
+}
+
+func loadCachedIndex(indexFile string, maxAge time.Duration) (*ChartReleases, error) {
+	info, err := os.Stat(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	if maxAge > 0 && time.Since(info.ModTime()) > maxAge {
+		return nil, fmt.Errorf("cached index %s is older than %s", indexFile, maxAge)
+	}
+	data, err := os.ReadFile(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	charts := &ChartReleases{}
+	err = yaml.Unmarshal(data, charts)
+	if err != nil {
+		return nil, err
+	}
+	return charts, nil
+}
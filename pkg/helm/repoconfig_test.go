@@ -0,0 +1,171 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testIndex = `apiVersion: v1
+entries:
+  test:
+  - name: test
+    version: 1.0.1
+  - name: test
+    version: 1.0.0
+`
+
+func TestRepoConfig_authorize(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("from-file\n"), 0600))
+	t.Setenv("NOVA_TEST_TOKEN", "from-env")
+
+	tests := []struct {
+		name   string
+		config RepoConfig
+		want   string
+	}{
+		{name: "none", config: RepoConfig{}, want: ""},
+		{name: "basic", config: RepoConfig{Username: "user", Password: "pass"}, want: "Basic dXNlcjpwYXNz"},
+		{name: "token", config: RepoConfig{Token: "abc"}, want: "Bearer abc"},
+		{name: "token env", config: RepoConfig{TokenEnv: "NOVA_TEST_TOKEN"}, want: "Bearer from-env"},
+		{name: "token file", config: RepoConfig{TokenFile: tokenFile}, want: "Bearer from-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest("GET", "https://example.com", nil)
+			assert.NoError(t, err)
+			assert.NoError(t, tt.config.authorize(r))
+			assert.Equal(t, tt.want, r.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestNewReposFromConfig_TLSAndAuth(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(testIndex))
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(caFile, ca, 0600))
+
+	repos, repoErrors := NewReposFromConfig([]RepoConfig{{URL: server.URL, Token: "secret", CAFile: caFile}}, nil)
+	assert.Empty(t, repoErrors)
+	if assert.Len(t, repos, 1) {
+		assert.Len(t, repos[0].Charts.Entries["test"], 2)
+	}
+
+	repos, repoErrors = NewReposFromConfig([]RepoConfig{{URL: server.URL, Token: "secret"}}, nil)
+	assert.Len(t, repos, 0)
+	assert.Len(t, repoErrors, 1, "the server certificate should not be trusted without the CA bundle")
+
+	repos, repoErrors = NewReposFromConfig([]RepoConfig{{URL: server.URL, CAFile: caFile}}, nil)
+	assert.Len(t, repos, 0)
+	if assert.Len(t, repoErrors, 1) {
+		assert.Equal(t, http.StatusUnauthorized, repoErrors[0].StatusCode)
+		assert.Equal(t, "Unauthorized", repoErrors[0].Error)
+	}
+}
+
+func TestLoadHelmRepositoryConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repositories.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`apiVersion: ""
+generated: "2024-05-02T09:00:00Z"
+repositories:
+- name: example
+  url: https://charts.example.com/
+  username: helm
+  password: secret
+  caFile: /tmp/ca.crt
+  certFile: /tmp/tls.crt
+  keyFile: /tmp/tls.key
+  insecure_skip_tls_verify: true
+  pass_credentials_all: false
+`), 0600))
+
+	got, err := LoadHelmRepositoryConfigs(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []RepoConfig{{
+		Name:               "example",
+		URL:                "https://charts.example.com",
+		Username:           "helm",
+		Password:           "secret",
+		CAFile:             "/tmp/ca.crt",
+		CertFile:           "/tmp/tls.crt",
+		KeyFile:            "/tmp/tls.key",
+		InsecureSkipVerify: true,
+	}}, got)
+
+	_, err = LoadHelmRepositoryConfigs(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestApplyHelmCredentials(t *testing.T) {
+	helmConfigs := []RepoConfig{
+		{URL: "https://charts.example.com", Username: "helm", Password: "helm"},
+	}
+	configs := []RepoConfig{
+		{URL: "https://charts.example.com/"},
+		{URL: "https://other.example.com"},
+		{URL: "https://charts.example.com", Token: "explicit"},
+	}
+	got := ApplyHelmCredentials(configs, helmConfigs)
+	assert.Equal(t, []RepoConfig{
+		{URL: "https://charts.example.com/", Username: "helm", Password: "helm"},
+		{URL: "https://other.example.com"},
+		{URL: "https://charts.example.com", Token: "explicit"},
+	}, got)
+}
+
+func TestNewReposFromHelmCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, "fresh-index.yaml"), []byte(testIndex), 0600))
+	stale := filepath.Join(cacheDir, "stale-index.yaml")
+	assert.NoError(t, os.WriteFile(stale, []byte(testIndex), 0600))
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(stale, old, old))
+
+	configs := []RepoConfig{
+		{Name: "fresh", URL: "https://fresh.example.com"},
+		{Name: "stale", URL: "https://stale.example.com"},
+		{Name: "missing", URL: "https://missing.example.com"},
+		{Name: "oci", URL: "oci://ghcr.io/example/charts/test"},
+	}
+
+	repos, uncached := NewReposFromHelmCache(configs, cacheDir, 24*time.Hour)
+	if assert.Len(t, repos, 1) {
+		assert.Equal(t, "https://fresh.example.com", repos[0].URL)
+		assert.Len(t, repos[0].Charts.Entries["test"], 2)
+	}
+	assert.Equal(t, configs[1:], uncached)
+
+	repos, uncached = NewReposFromHelmCache(configs, cacheDir, 0)
+	assert.Len(t, repos, 2)
+	assert.Equal(t, configs[2:], uncached)
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultArtifactHubTimeout is how long a single request to a hub may take, including reading the response
+	DefaultArtifactHubTimeout = time.Minute
+	// DefaultArtifactHubMaxRetries is how often a failed request to a hub is retried
+	DefaultArtifactHubMaxRetries = 4
+	// DefaultArtifactHubMaxRetryWait is the longest a Retry-After header may ask nova to wait before a request is retried
+	DefaultArtifactHubMaxRetryWait = 5 * time.Minute
+	initialRetryBackoff            = time.Second
+	// maxRetryBackoff is the longest nova waits before retrying when the response has no Retry-After header
+	maxRetryBackoff = 30 * time.Second
+)
+
+// artifactHubLimiter is shared by every artifacthub client, so that all requests together stay below
+// maxArtifactHubRequestLimit requests per minute
+var artifactHubLimiter = rate.NewLimiter(rate.Every(time.Minute/maxArtifactHubRequestLimit), maxArtifactHubRequestLimit)
+
+// retryTransport retries requests that failed with a network error, 429 Too Many Requests or a server error.
+// It waits with exponential backoff between attempts, or as long as the Retry-After header of the response asks,
+// giving up if that is longer than maxRetryWait. Every attempt waits for the rate limiter and has its own timeout.
+type retryTransport struct {
+	base         http.RoundTripper
+	limiter      *rate.Limiter
+	timeout      time.Duration
+	maxRetries   int
+	maxRetryWait time.Duration
+	backoff      time.Duration
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		if t.limiter != nil {
+			if err := t.limiter.Wait(r.Context()); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := t.roundTrip(r)
+		if attempt > t.maxRetries || !retryable(r, resp, err) {
+			return resp, err
+		}
+		wait := min(backoff, maxRetryBackoff)
+		if err != nil {
+			klog.V(3).Infof("attempt %d failed to GET %s: %v", attempt, r.URL, err)
+		} else {
+			klog.V(3).Infof("attempt %d failed to GET %s with status code: %d", attempt, r.URL, resp.StatusCode)
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > t.maxRetryWait {
+					klog.Warningf("giving up on %s after %d attempts, the server asked to wait %s before retrying, which is longer than the maximum retry wait of %s", r.URL, attempt, retryAfter, t.maxRetryWait)
+					return resp, nil
+				}
+				wait = retryAfter
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// roundTrip sends a single attempt. The timeout covers reading the body, so it is only cancelled when the body is closed.
+func (t *retryTransport) roundTrip(r *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(r)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// retryable returns true if the attempt failed in a way that may succeed when retried
+func retryable(r *http.Request, resp *http.Response, err error) bool {
+	if r.Body != nil && r.Body != http.NoBody {
+		return false // the body was already consumed
+	}
+	if r.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an http date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []int
+		retryAfter   string
+		maxRetries   int
+		maxRetryWait time.Duration
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{
+			name:         "retries rate limits and server errors",
+			responses:    []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK},
+			retryAfter:   "0",
+			maxRetries:   4,
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name:         "gives up after max retries",
+			responses:    []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			maxRetries:   2,
+			wantStatus:   http.StatusBadGateway,
+			wantAttempts: 3,
+		},
+		{
+			name:         "does not retry client errors",
+			responses:    []int{http.StatusNotFound, http.StatusOK},
+			maxRetries:   4,
+			wantStatus:   http.StatusNotFound,
+			wantAttempts: 1,
+		},
+		{
+			name:         "waits as long as retry-after asks",
+			responses:    []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "1",
+			maxRetries:   4,
+			maxRetryWait: time.Minute,
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name:         "gives up when retry-after is longer than the max retry wait",
+			responses:    []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "3600",
+			maxRetries:   4,
+			maxRetryWait: time.Minute,
+			wantStatus:   http.StatusTooManyRequests,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := atomic.AddInt32(&attempts, 1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.responses[attempt-1])
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: &retryTransport{
+				base:         http.DefaultTransport,
+				maxRetries:   tt.maxRetries,
+				maxRetryWait: tt.maxRetryWait,
+				backoff:      time.Millisecond,
+			}}
+			resp, err := client.Get(server.URL)
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantAttempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestRetryTransport_Timeout(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{
+		base:       http.DefaultTransport,
+		timeout:    100 * time.Millisecond,
+		maxRetries: 1,
+		backoff:    time.Millisecond,
+	}}
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	wait, ok := parseRetryAfter("120")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, wait)
+
+	wait, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	wait, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour.Seconds(), wait.Seconds(), 5)
+
+	_, ok = parseRetryAfter("")
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"k8s.io/klog/v2"
+)
+
+// GetSecurityReportSummary returns the security report summary of a single version of a package, or nil if
+// artifacthub has not scanned that version.
+func (ac *ArtifactHubPackageClient) GetSecurityReportSummary(repoName, packageName, version string) (*output.SecuritySummary, error) {
+	response := ac.getSpecific(fmt.Sprintf("api/v1/packages/helm/%s/%s/%s", repoName, packageName, version))
+	if response.err != nil {
+		return nil, response.err
+	}
+	summary := response.Package.SecurityReportSummary
+	if summary == nil {
+		return nil, nil
+	}
+	return &output.SecuritySummary{
+		Critical: summary.Critical,
+		High:     summary.High,
+		Medium:   summary.Medium,
+		Low:      summary.Low,
+		Unknown:  summary.Unknown,
+	}, nil
+}
+
+// AddSecurityReports sets the security report summaries of the installed and latest versions of every release
+// that was matched to a package listed by the hub of the client. Releases matched against a chart repository or
+// another hub are left unchanged.
+func (ac *ArtifactHubPackageClient) AddSecurityReports(releases []output.ReleaseOutput) {
+	type report struct {
+		repository, chart, version string
+	}
+	summaries := map[report]*output.SecuritySummary{}
+	for _, rls := range releases {
+		selected := rls.Match.Selected()
+		if selected == nil || selected.Hub != ac.Hub.Name {
+			continue
+		}
+		for _, v := range []string{rls.Installed.Version, rls.Latest.Version} {
+			if v != "" {
+				summaries[report{selected.Repository, selected.Package, v}] = nil
+			}
+		}
+	}
+
+	reports := make([]report, 0, len(summaries))
+	for r := range summaries {
+		reports = append(reports, r)
+	}
+	wg := sync.WaitGroup{}
+	mut := sync.Mutex{}
+	for _, r := range reports {
+		wg.Add(1)
+		go func(r report) {
+			defer wg.Done()
+			summary, err := ac.GetSecurityReportSummary(r.repository, r.chart, r.version)
+			if err != nil {
+				klog.V(3).Infof("error getting security report for %s/%s %s: %s", r.repository, r.chart, r.version, err)
+				return
+			}
+			mut.Lock()
+			defer mut.Unlock()
+			summaries[r] = summary
+		}(r)
+	}
+	wg.Wait()
+
+	for i, rls := range releases {
+		selected := rls.Match.Selected()
+		if selected == nil || selected.Hub != ac.Hub.Name {
+			continue
+		}
+		releases[i].Installed.Security = summaries[report{selected.Repository, selected.Package, rls.Installed.Version}]
+		releases[i].Latest.Security = summaries[report{selected.Repository, selected.Package, rls.Latest.Version}]
+	}
+}
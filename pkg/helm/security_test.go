@@ -0,0 +1,84 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactHubPackageClient_AddSecurityReports(t *testing.T) {
+	reports := map[string]string{
+		"/api/v1/packages/helm/bitnami/redis/15.4.1":     `{"name": "redis", "security_report_summary": {"critical": 2, "high": 5, "medium": 1, "low": 0}}`,
+		"/api/v1/packages/helm/bitnami/redis/15.5.5":     `{"name": "redis", "security_report_summary": {"critical": 0, "high": 1, "medium": 3, "low": 2}}`,
+		"/api/v1/packages/helm/fairwinds/goldilocks/3.3": `{"name": "goldilocks", "security_report_summary": {"critical": 0, "high": 7}}`,
+		"/api/v1/packages/helm/fairwinds/goldilocks/4.0": `{"name": "goldilocks"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := reports[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	ac := &ArtifactHubPackageClient{URL: u, Client: server.Client()}
+
+	matched := func(repository, chart string) *output.MatchInfo {
+		return &output.MatchInfo{Candidates: []output.MatchCandidate{
+			{Repository: "other", Package: chart},
+			{Repository: repository, Package: chart, Selected: true},
+		}}
+	}
+	out := output.Output{HelmReleases: []output.ReleaseOutput{
+		{
+			ReleaseName: "from-repo",
+			Installed:   output.VersionInfo{Version: "1.0.0"},
+		},
+		{
+			ReleaseName: "goldilocks",
+			Installed:   output.VersionInfo{Version: "3.3"},
+			Latest:      output.VersionInfo{Version: "4.0"},
+			Match:       matched("fairwinds", "goldilocks"),
+		},
+		{
+			ReleaseName: "redis",
+			Installed:   output.VersionInfo{Version: "15.4.1"},
+			Latest:      output.VersionInfo{Version: "15.5.5"},
+			Match:       matched("bitnami", "redis"),
+		},
+	}}
+	ac.AddSecurityReports(out.HelmReleases)
+
+	assert.Nil(t, out.HelmReleases[0].Installed.Security)
+	assert.Equal(t, &output.SecuritySummary{High: 7}, out.HelmReleases[1].Installed.Security)
+	assert.Nil(t, out.HelmReleases[1].Latest.Security)
+	assert.Equal(t, &output.SecuritySummary{Critical: 2, High: 5, Medium: 1}, out.HelmReleases[2].Installed.Security)
+	assert.Equal(t, "C:0 H:1 M:3 L:2", out.HelmReleases[2].Latest.Security.String())
+
+	out.SortByVulnerabilities()
+	var order []string
+	for _, rls := range out.HelmReleases {
+		order = append(order, rls.ReleaseName)
+	}
+	assert.Equal(t, []string{"redis", "goldilocks", "from-repo"}, order)
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"sort"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/fairwindsops/nova/pkg/versions"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// SetUpgradePaths stores the available versions of the chart of a release, newest first, and sets the newest
+// versions that upgrade the installed version by a patch, a minor or a major version. Pre-release versions are
+// left out unless includePrereleases is set.
+func SetUpgradePaths(rls *output.ReleaseOutput, available []output.VersionInfo, includePrereleases bool) {
+	type parsedVersion struct {
+		info    output.VersionInfo
+		version *semver.Version
+	}
+	var parsed []parsedVersion
+	seen := map[string]bool{}
+	for _, v := range available {
+		if seen[v.Version] || !versions.IsValid(v.Version, includePrereleases) {
+			continue
+		}
+		sv, err := versions.Parse(v.Version)
+		if err != nil {
+			continue
+		}
+		seen[v.Version] = true
+		parsed = append(parsed, parsedVersion{info: v, version: sv})
+	}
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return parsed[i].version.GreaterThan(parsed[j].version)
+	})
+
+	rls.AvailableVersions = make([]output.VersionInfo, len(parsed))
+	for i, p := range parsed {
+		rls.AvailableVersions[i] = p.info
+	}
+	rls.LatestPatchVersion, rls.LatestMinorVersion, rls.LatestMajorVersion = "", "", ""
+	installed, err := versions.Parse(rls.Installed.Version)
+	if err != nil {
+		return
+	}
+	// versions are sorted newest first, so the first match of each kind is the newest one
+	for _, p := range parsed {
+		v := p.version
+		switch {
+		case !v.GreaterThan(installed):
+			continue
+		case v.Major() > installed.Major():
+			if rls.LatestMajorVersion == "" {
+				rls.LatestMajorVersion = p.info.Version
+			}
+		case v.Major() == installed.Major() && v.Minor() > installed.Minor():
+			if rls.LatestMinorVersion == "" {
+				rls.LatestMinorVersion = p.info.Version
+			}
+		case v.Major() == installed.Major() && v.Minor() == installed.Minor():
+			if rls.LatestPatchVersion == "" {
+				rls.LatestPatchVersion = p.info.Version
+			}
+		}
+	}
+}
+
+// ChartVersions returns every version of a chart in the repo
+func (r *Repo) ChartVersions(chartName string) []output.VersionInfo {
+	var versions []output.VersionInfo
+	for _, entry := range r.Charts.Entries[chartName] {
+		versions = append(versions, output.VersionInfo{
+			Version:     entry.Version,
+			AppVersion:  entry.AppVersion,
+			KubeVersion: entry.KubeVersion,
+		})
+	}
+	return versions
+}
+
+// chartVersionsFromRepos returns every version of the chart of a release in the repos that contain the installed chart
+func chartVersionsFromRepos(rls *release.Release, repos []*Repo) []output.VersionInfo {
+	var versions []output.VersionInfo
+	for _, repo := range repos {
+		if repo.NewestChartVersion(rls.Chart.Metadata, true) != nil {
+			versions = append(versions, repo.ChartVersions(rls.Chart.Metadata.Name)...)
+		}
+	}
+	return versions
+}
+
+// packageVersions returns every version of an artifacthub package
+func packageVersions(pkg ArtifactHubHelmPackage) []output.VersionInfo {
+	versions := make([]output.VersionInfo, 0, len(pkg.AvailableVersions))
+	for _, v := range pkg.AvailableVersions {
+		versions = append(versions, output.VersionInfo{
+			Version:     v.Version,
+			AppVersion:  v.AppVersion,
+			KubeVersion: v.KubeVersion,
+		})
+	}
+	return versions
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"testing"
+
+	"github.com/fairwindsops/nova/pkg/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetUpgradePaths(t *testing.T) {
+	versions := func(vs ...string) []output.VersionInfo {
+		ret := make([]output.VersionInfo, len(vs))
+		for i, v := range vs {
+			ret[i] = output.VersionInfo{Version: v}
+		}
+		return ret
+	}
+	tests := []struct {
+		name          string
+		installed     string
+		available     []output.VersionInfo
+		wantPatch     string
+		wantMinor     string
+		wantMajor     string
+		wantAvailable []string
+	}{
+		{
+			name:          "all upgrades",
+			installed:     "1.2.3",
+			available:     versions("1.2.3", "2.1.0", "1.2.4", "1.3.0", "1.4.1", "2.0.0", "1.2.5", "3.0.0-rc1", "1.2.2"),
+			wantPatch:     "1.2.5",
+			wantMinor:     "1.4.1",
+			wantMajor:     "2.1.0",
+			wantAvailable: []string{"2.1.0", "2.0.0", "1.4.1", "1.3.0", "1.2.5", "1.2.4", "1.2.3", "1.2.2"},
+		},
+		{
+			name:          "only a major upgrade",
+			installed:     "v4.0.0",
+			available:     versions("4.0.0", "5.0.1", "3.9.0", "not-a-version", "5.0.1"),
+			wantMajor:     "5.0.1",
+			wantAvailable: []string{"5.0.1", "4.0.0", "3.9.0"},
+		},
+		{
+			name:          "up to date",
+			installed:     "1.0.0",
+			available:     versions("1.0.0", "0.9.0"),
+			wantAvailable: []string{"1.0.0", "0.9.0"},
+		},
+		{
+			name:          "unparsable installed version",
+			installed:     "latest",
+			available:     versions("1.0.0"),
+			wantAvailable: []string{"1.0.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rls := &output.ReleaseOutput{Installed: output.VersionInfo{Version: tt.installed}}
+			SetUpgradePaths(rls, tt.available, false)
+			assert.Equal(t, tt.wantPatch, rls.LatestPatchVersion)
+			assert.Equal(t, tt.wantMinor, rls.LatestMinorVersion)
+			assert.Equal(t, tt.wantMajor, rls.LatestMajorVersion)
+			var available []string
+			for _, v := range rls.AvailableVersions {
+				available = append(available, v.Version)
+			}
+			assert.Equal(t, tt.wantAvailable, available)
+		})
+	}
+}
+
+func TestRepo_ChartVersions(t *testing.T) {
+	repo := &Repo{Charts: &ChartReleases{Entries: map[string][]ChartRelease{
+		"redis": {
+			{Name: "redis", Version: "15.5.5", AppVersion: "6.2.6"},
+			{Name: "redis", Version: "15.4.1", AppVersion: "6.2.5", KubeVersion: ">=1.19.0"},
+		},
+	}}}
+	assert.Equal(t, []output.VersionInfo{
+		{Version: "15.5.5", AppVersion: "6.2.6"},
+		{Version: "15.4.1", AppVersion: "6.2.5", KubeVersion: ">=1.19.0"},
+	}, repo.ChartVersions("redis"))
+	assert.Empty(t, repo.ChartVersions("nginx"))
+}
@@ -20,13 +20,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/fairwindsops/nova/pkg/containers"
-	"helm.sh/helm/v3/pkg/release"
+	"github.com/fairwindsops/nova/pkg/versions"
+	helmrelease "helm.sh/helm/v3/pkg/release"
 
 	"k8s.io/klog/v2"
 )
@@ -36,12 +41,55 @@ const (
 	JSONFormat = "json"
 	// TableFormat table/csv output format
 	TableFormat = "table"
+
+	// AppDriftMajor, AppDriftMinor and AppDriftPatch are the parts of an app version that can be behind the latest
+	AppDriftMajor = "major"
+	AppDriftMinor = "minor"
+	AppDriftPatch = "patch"
 )
 
 // Output is the object that Nova outputs
 type Output struct {
 	HelmReleases []ReleaseOutput `json:"helm"`
 	IncludeAll   bool            `json:"include_all"`
+	AllStatuses  bool            `json:"all_statuses"`
+	// Vulnerabilities adds the security report summaries of the installed and latest versions to the wide table
+	Vulnerabilities bool `json:"-"`
+	// Wide adds the columns of the wide table to the csv output
+	Wide       bool        `json:"-"`
+	RepoErrors []RepoError `json:"repo_errors,omitempty"`
+	// ArtifactHubCache describes the age of the ArtifactHub package list the releases were matched against
+	ArtifactHubCache *CacheInfo `json:"artifacthub_cache,omitempty"`
+	// MinAppDrift limits --show-old to releases whose app version is behind by at least this part, instead of releases
+	// whose chart version is behind
+	MinAppDrift string `json:"-"`
+	// KubeVersion is the Kubernetes version the kubeVersion constraints of the latest chart versions were checked against
+	KubeVersion string `json:"kube_version,omitempty"`
+}
+
+// CacheInfo describes when cached data was downloaded
+type CacheInfo struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	// Age is the time between FetchedAt and the creation of the output, e.g. 2h5m0s
+	Age string `json:"age"`
+	// Stale is true if the data could not be refreshed and an expired copy was used
+	Stale bool `json:"stale"`
+}
+
+// NewCacheInfo returns the cache info for data fetched at the given time
+func NewCacheInfo(fetchedAt time.Time, stale bool) *CacheInfo {
+	return &CacheInfo{
+		FetchedAt: fetchedAt,
+		Age:       time.Since(fetchedAt).Round(time.Second).String(),
+		Stale:     stale,
+	}
+}
+
+// RepoError is a chart repository that could not be loaded
+type RepoError struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error"`
 }
 
 // ContainersOutput represents the output data we need for displaying a table of out of date container images
@@ -68,10 +116,211 @@ type ReleaseOutput struct {
 	Icon        string `json:"icon,omitempty"`
 	Installed   VersionInfo
 	Latest      VersionInfo
-	IsOld       bool   `json:"outdated"`
-	Deprecated  bool   `json:"deprecated"`
+	// LatestPatchVersion, LatestMinorVersion and LatestMajorVersion are the newest versions that upgrade the installed
+	// version by a patch, minor or major version. They are empty if there is no such upgrade.
+	LatestPatchVersion string `json:"latestPatchVersion,omitempty"`
+	LatestMinorVersion string `json:"latestMinorVersion,omitempty"`
+	LatestMajorVersion string `json:"latestMajorVersion,omitempty"`
+	// KubeIncompatible is true when the kubeVersion of the latest version does not allow the Kubernetes version of the cluster
+	KubeIncompatible bool `json:"kubeIncompatible,omitempty"`
+	// LatestCompatibleVersion is the newest version that allows the Kubernetes version of the cluster, when the latest version does not
+	LatestCompatibleVersion string `json:"latestCompatibleVersion,omitempty"`
+	// AvailableVersions are the released versions of the chart, newest first
+	AvailableVersions []VersionInfo `json:"-"`
+	IsOld             bool          `json:"outdated"`
+	// AppDrift compares the app version of the installed and latest chart versions
+	AppDrift   *AppVersionDrift `json:"appDrift,omitempty"`
+	Deprecated bool             `json:"deprecated"`
+	// DeprecationReason explains why a deprecated chart is deprecated, if known
+	DeprecationReason string `json:"deprecationReason,omitempty"`
+	// Replacement is a link to, or the repository/name of, the chart that replaces a deprecated chart, if known
+	Replacement string `json:"replacement,omitempty"`
 	HelmVersion string `json:"helmVersion"`
 	Overridden  bool   `json:"overridden"`
+	// DesiredVersion is the desired-versions constraint the latest version had to satisfy, if any
+	DesiredVersion string `json:"desiredVersion,omitempty"`
+	// NewestVersion is the newest version of the chart when the latest version was limited by a desired-versions constraint
+	NewestVersion string `json:"newestVersion,omitempty"`
+	// Status is the helm status of the release, e.g. deployed, failed or pending-upgrade
+	Status       string    `json:"status,omitempty"`
+	LastDeployed time.Time `json:"lastDeployed,omitzero"`
+	// ManagedBy is the gitops object that declares this chart, if any
+	ManagedBy *GitOpsOwner `json:"managedBy,omitempty"`
+	// MatchScore is the score of the artifacthub package the release was matched to. Higher scores are more likely to be correct.
+	MatchScore float32 `json:"matchScore,omitempty"`
+	// Ambiguous is true when another artifacthub package scored the same as, or close to, the matched package
+	Ambiguous bool `json:"ambiguous,omitempty"`
+	// Match explains how the artifacthub package was chosen. It is only included when requested.
+	Match *MatchInfo `json:"match,omitempty"`
+	// PossiblyMovedTo lists artifacthub packages with a different name that may be the new home of a chart that
+	// was not found or is deprecated
+	PossiblyMovedTo []MovedChart `json:"possiblyMovedTo,omitempty"`
+	// DeprecatedAPIs lists the objects in the manifest of the release that use an API version that is deprecated
+	// or removed in the Kubernetes version that was checked
+	DeprecatedAPIs []DeprecatedAPI `json:"deprecatedAPIs,omitempty"`
+}
+
+// appDriftRank orders the app drift levels from the smallest to the largest change
+var appDriftRank = map[string]int{AppDriftPatch: 1, AppDriftMinor: 2, AppDriftMajor: 3}
+
+// IsValidAppDrift reports whether a string is an app drift level
+func IsValidAppDrift(level string) bool {
+	_, ok := appDriftRank[level]
+	return ok
+}
+
+// AppVersionDrift describes how far the app version of the installed chart version is behind the latest one
+type AppVersionDrift struct {
+	// Changed is true when the latest chart version packages a different app version
+	Changed bool `json:"changed"`
+	// Level is the largest part of the app version that is behind: major, minor or patch. It is empty when the
+	// latest app version is not newer, or either app version is not a semantic version.
+	Level string `json:"level,omitempty"`
+	// MajorBehind, MinorBehind and PatchBehind count how many versions the app is behind at its Level
+	MajorBehind int `json:"majorBehind"`
+	MinorBehind int `json:"minorBehind"`
+	PatchBehind int `json:"patchBehind"`
+}
+
+// SetAppDrift compares the app versions of the installed and latest chart versions. It is left empty when either
+// chart version has no app version.
+func (r *ReleaseOutput) SetAppDrift() {
+	r.AppDrift = nil
+	if r.Installed.AppVersion == "" || r.Latest.AppVersion == "" {
+		return
+	}
+	drift := &AppVersionDrift{Changed: r.Installed.AppVersion != r.Latest.AppVersion}
+	r.AppDrift = drift
+	installed, err := versions.Parse(r.Installed.AppVersion)
+	if err != nil {
+		return
+	}
+	latest, err := versions.Parse(r.Latest.AppVersion)
+	if err != nil || !latest.GreaterThan(installed) {
+		return
+	}
+	switch {
+	case latest.Major() > installed.Major():
+		drift.Level = AppDriftMajor
+		drift.MajorBehind = int(latest.Major() - installed.Major())
+	case latest.Minor() > installed.Minor():
+		drift.Level = AppDriftMinor
+		drift.MinorBehind = int(latest.Minor() - installed.Minor())
+	case latest.Patch() > installed.Patch():
+		drift.Level = AppDriftPatch
+		drift.PatchBehind = int(latest.Patch() - installed.Patch())
+	}
+}
+
+// IsBehind reports whether a release is shown by --show-old. Without minAppDrift the chart version has to be
+// behind the latest, otherwise the app version has to be behind by at least that part (major, minor or patch).
+// An app version that changed but is not a semantic version counts as a patch.
+func (r ReleaseOutput) IsBehind(minAppDrift string) bool {
+	if minAppDrift == "" {
+		return r.IsOld
+	}
+	if r.AppDrift == nil || !r.AppDrift.Changed {
+		return false
+	}
+	level := r.AppDrift.Level
+	if level == "" {
+		_, errInstalled := versions.Parse(r.Installed.AppVersion)
+		_, errLatest := versions.Parse(r.Latest.AppVersion)
+		if errInstalled == nil && errLatest == nil {
+			return false
+		}
+		level = AppDriftPatch
+	}
+	return appDriftRank[level] >= appDriftRank[minAppDrift]
+}
+
+// String formats the drift for the table output, e.g. major (2), or changed when the app versions are not comparable
+func (d *AppVersionDrift) String() string {
+	switch {
+	case d == nil || !d.Changed:
+		return ""
+	case d.Level == AppDriftMajor:
+		return fmt.Sprintf("%s (%d)", d.Level, d.MajorBehind)
+	case d.Level == AppDriftMinor:
+		return fmt.Sprintf("%s (%d)", d.Level, d.MinorBehind)
+	case d.Level == AppDriftPatch:
+		return fmt.Sprintf("%s (%d)", d.Level, d.PatchBehind)
+	default:
+		return "changed"
+	}
+}
+
+// DeprecatedAPI is an object in the manifest of a release that uses a deprecated or removed API version
+type DeprecatedAPI struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	APIVersion string `json:"apiVersion"`
+	// DeprecatedIn and RemovedIn are the Kubernetes versions that deprecate and remove the API version, e.g. 1.19
+	DeprecatedIn string `json:"deprecatedIn"`
+	RemovedIn    string `json:"removedIn,omitempty"`
+	// Removed is true when the API version is no longer served by the Kubernetes version that was checked
+	Removed bool `json:"removed"`
+	// Replacement is the API version to migrate to, if there is one
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// MovedChart is an artifacthub package that may be the new name or location of a chart
+type MovedChart struct {
+	Repository string `json:"repository"`
+	ChartName  string `json:"chartName"`
+	Version    string `json:"version"`
+	// Reasons lists what the package has in common with the installed chart, e.g. source-link or maintainers
+	Reasons []string `json:"reasons"`
+}
+
+// MatchInfo lists the artifacthub packages that were considered for a release
+type MatchInfo struct {
+	// PinnedRepository is set when the release is pinned to an artifacthub repository, in which case candidates are not scored
+	PinnedRepository string           `json:"pinnedRepository,omitempty"`
+	Candidates       []MatchCandidate `json:"candidates"`
+	// Ambiguous is true when the runner-up scored the same as, or close to, the selected candidate
+	Ambiguous bool `json:"ambiguous"`
+}
+
+// Selected returns the candidate that was matched to the release, or nil if none was
+func (m *MatchInfo) Selected() *MatchCandidate {
+	if m == nil {
+		return nil
+	}
+	for i := range m.Candidates {
+		if m.Candidates[i].Selected {
+			return &m.Candidates[i]
+		}
+	}
+	return nil
+}
+
+// MatchCandidate is an artifacthub package that was considered for a release, and how it scored
+type MatchCandidate struct {
+	// Hub is the name of the artifacthub instance that listed the package
+	Hub        string           `json:"hub,omitempty"`
+	Repository string           `json:"repository"`
+	Package    string           `json:"package"`
+	Version    string           `json:"version"`
+	Stars      int              `json:"stars"`
+	Criteria   []MatchCriterion `json:"criteria"`
+	// StarBonus is added to the package with the most stars if any package has enough stars
+	StarBonus float32 `json:"starBonus"`
+	Score     float32 `json:"score"`
+	Selected  bool    `json:"selected"`
+}
+
+// MatchCriterion is a scoring criterion that an artifacthub package matched
+type MatchCriterion struct {
+	Name  string  `json:"name"`
+	Score float32 `json:"score"`
+}
+
+// GitOpsOwner is a Flux HelmRelease or Argo CD Application that manages a helm chart
+type GitOpsOwner struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
 }
 
 // WorkloadOutput represents a workload
@@ -98,10 +347,57 @@ type VersionInfo struct {
 	Version     string `json:"version"`
 	AppVersion  string `json:"appVersion"`
 	KubeVersion string `json:"kubeVersion"`
+	// Security is the artifacthub security report summary of the chart version, if it was requested and is known
+	Security *SecuritySummary `json:"security,omitempty"`
+}
+
+// SecuritySummary counts the known vulnerabilities in the images of a chart version by severity
+type SecuritySummary struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Unknown  int `json:"unknown"`
+}
+
+// String returns the summary as e.g. C:1 H:4 M:10 L:2, or an empty string if the summary is unknown
+func (s *SecuritySummary) String() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("C:%d H:%d M:%d L:%d", s.Critical, s.High, s.Medium, s.Low)
+}
+
+// Less reports whether s has fewer or less severe vulnerabilities than other. Severities are compared from
+// critical to unknown, and an unknown summary is less than any known one.
+func (s *SecuritySummary) Less(other *SecuritySummary) bool {
+	if other == nil {
+		return false
+	}
+	if s == nil {
+		return true
+	}
+	a := []int{s.Critical, s.High, s.Medium, s.Low, s.Unknown}
+	b := []int{other.Critical, other.High, other.Medium, other.Low, other.Unknown}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// SetReleaseInfo populates the status and last deployed time from the helm release info
+func (release *ReleaseOutput) SetReleaseInfo(info *helmrelease.Info) {
+	if info == nil {
+		return
+	}
+	release.Status = info.Status.String()
+	release.LastDeployed = info.LastDeployed.Time
 }
 
 // NewOutputWithHelmReleases creates a new output object with the given helm releases pre-populated with the installed version
-func NewOutputWithHelmReleases(helmReleases []*release.Release) Output {
+func NewOutputWithHelmReleases(helmReleases []*helmrelease.Release) Output {
 	var output Output
 	for _, helmRelease := range helmReleases {
 		var release ReleaseOutput
@@ -117,6 +413,7 @@ func NewOutputWithHelmReleases(helmReleases []*release.Release) Output {
 			KubeVersion: helmRelease.Chart.Metadata.KubeVersion,
 		}
 		release.HelmVersion = "3"
+		release.SetReleaseInfo(helmRelease.Info)
 		output.HelmReleases = append(output.HelmReleases, release)
 	}
 	return output
@@ -145,11 +442,42 @@ func (output Output) ToFile(filename string) error {
 		}
 		w := csv.NewWriter(file)
 		defer w.Flush()
+		// optional columns are appended after the original ones so that their positions do not change
 		header := []string{"Release Name", "Chart Name", "Namespace", "HelmVersion", "Installed", "Latest", "Old", "Deprecated"}
+		if output.Wide {
+			header = append(header, "Latest Patch", "Latest Minor", "Latest Major", "Installed App", "Latest App", "App Drift", "Match Score", "Ambiguous")
+		}
+		if output.Wide && output.KubeVersion != "" {
+			header = append(header, "Kube Compatible", "Latest Compatible")
+		}
+		if output.Wide && output.Vulnerabilities {
+			header = append(header, "Installed Vulnerabilities", "Latest Vulnerabilities")
+		}
+		if output.AllStatuses {
+			header = append(header, "Status", "Last Deployed")
+		}
+		if output.KubeVersion != "" {
+			header = append(header, "Deprecated APIs")
+		}
 		var data [][]string
 		data = append(data, header)
 		for _, rl := range output.HelmReleases {
 			row := []string{rl.ReleaseName, rl.ChartName, rl.Namespace, rl.HelmVersion, rl.Installed.Version, rl.Latest.Version, strconv.FormatBool(rl.IsOld), strconv.FormatBool(rl.Deprecated)}
+			if output.Wide {
+				row = append(row, rl.LatestPatchVersion, rl.LatestMinorVersion, rl.LatestMajorVersion, rl.Installed.AppVersion, rl.Latest.AppVersion, rl.AppDrift.String(), formatMatchScore(rl), strconv.FormatBool(rl.Ambiguous))
+			}
+			if output.Wide && output.KubeVersion != "" {
+				row = append(row, output.formatKubeCompatible(rl), rl.LatestCompatibleVersion)
+			}
+			if output.Wide && output.Vulnerabilities {
+				row = append(row, rl.Installed.Security.String(), rl.Latest.Security.String())
+			}
+			if output.AllStatuses {
+				row = append(row, rl.Status, formatTime(rl.LastDeployed))
+			}
+			if output.KubeVersion != "" {
+				row = append(row, formatDeprecatedAPIs(rl.DeprecatedAPIs))
+			}
 			data = append(data, row)
 		}
 		w.WriteAll(data)
@@ -161,31 +489,70 @@ func (output Output) ToFile(filename string) error {
 
 // Print sends the output to STDOUT
 func (output Output) Print(format string, wide, showOld bool) {
-	if len(output.HelmReleases) == 0 {
+	if len(output.HelmReleases) == 0 && len(output.RepoErrors) == 0 {
 		fmt.Println("No releases found")
 		return
 	}
 	switch format {
 	case JSONFormat:
+		// stdout stays a list of releases, repository errors go to stderr
 		data, _ := marshalWithoutHTMLEscaping(output.HelmReleases)
 		fmt.Fprintln(os.Stdout, string(data))
+		output.printRepoErrors(os.Stderr)
 	case TableFormat:
+		if len(output.HelmReleases) == 0 {
+			fmt.Println("No releases found")
+			output.printRepoErrors(os.Stdout)
+			return
+		}
 		w := tabwriter.NewWriter(os.Stdout, 0, 4, 4, ' ', 0)
 		header := "Release Name\t"
 		if wide {
-			header += "Chart Name\tNamespace\tHelmVersion\t"
+			header += "Chart Name\tNamespace\tHelmVersion\tManaged By\tMatch Score\t"
+		}
+		header += "Installed\tLatest\t"
+		if wide {
+			header += "Latest Patch\tLatest Minor\tLatest Major\tInstalled App\tLatest App\tApp Drift\t"
+		}
+		if wide && output.KubeVersion != "" {
+			header += "Kube Compatible\tLatest Compatible\t"
+		}
+		header += "Old\tDeprecated"
+		if wide {
+			header += "\tReplacement\tDeprecation Reason"
+		}
+		if wide && output.Vulnerabilities {
+			header += "\tInstalled Vulnerabilities\tLatest Vulnerabilities"
+		}
+		if output.AllStatuses {
+			header += "\tStatus\tLast Deployed"
 		}
-		header += "Installed\tLatest\tOld\tDeprecated"
 		fmt.Fprintln(w, header)
 		separator := "============\t"
 		if wide {
-			separator += "==========\t=========\t===========\t"
+			separator += "==========\t=========\t===========\t==========\t===========\t"
+		}
+		separator += "=========\t======\t"
+		if wide {
+			separator += "============\t============\t============\t=============\t==========\t=========\t"
+		}
+		if wide && output.KubeVersion != "" {
+			separator += "===============\t=================\t"
+		}
+		separator += "===\t=========="
+		if wide {
+			separator += "\t===========\t=================="
+		}
+		if wide && output.Vulnerabilities {
+			separator += "\t=========================\t======================"
+		}
+		if output.AllStatuses {
+			separator += "\t======\t============="
 		}
-		separator += "=========\t======\t===\t=========="
 		fmt.Fprintln(w, separator)
 
 		for _, release := range output.HelmReleases {
-			if (!output.IncludeAll && release.Latest.Version == "") || (showOld && !release.IsOld) {
+			if (!output.IncludeAll && release.Latest.Version == "") || (showOld && !release.IsBehind(output.MinAppDrift)) {
 				continue
 			}
 			line := release.ReleaseName + "\t"
@@ -193,19 +560,121 @@ func (output Output) Print(format string, wide, showOld bool) {
 				line += release.ChartName + "\t"
 				line += release.Namespace + "\t"
 				line += release.HelmVersion + "\t"
+				line += release.ManagedBy.String() + "\t"
+				line += formatMatchScore(release) + "\t"
 			}
 			line += release.Installed.Version + "\t"
 			line += release.Latest.Version + "\t"
+			if wide {
+				line += release.LatestPatchVersion + "\t"
+				line += release.LatestMinorVersion + "\t"
+				line += release.LatestMajorVersion + "\t"
+				line += release.Installed.AppVersion + "\t"
+				line += release.Latest.AppVersion + "\t"
+				line += release.AppDrift.String() + "\t"
+			}
+			if wide && output.KubeVersion != "" {
+				line += output.formatKubeCompatible(release) + "\t"
+				line += release.LatestCompatibleVersion + "\t"
+			}
 			line += fmt.Sprintf("%t", release.IsOld) + "\t"
 			line += fmt.Sprintf("%t", release.Deprecated) + "\t"
+			if wide {
+				line += release.Replacement + "\t"
+				line += release.DeprecationReason + "\t"
+			}
+			if wide && output.Vulnerabilities {
+				line += release.Installed.Security.String() + "\t"
+				line += release.Latest.Security.String() + "\t"
+			}
+			if output.AllStatuses {
+				line += release.Status + "\t"
+				line += formatTime(release.LastDeployed) + "\t"
+			}
 			fmt.Fprintln(w, line)
 		}
 		w.Flush()
+		output.printMovedCharts()
+		output.printDeprecatedAPIs()
+		output.printRepoErrors(os.Stdout)
 	default:
 		klog.Errorf("Output format is not supported. The supported formats are json and table only")
 	}
 }
 
+// formatDeprecatedAPIs lists deprecated APIs as Kind/name (apiVersion), separated by semicolons
+func formatDeprecatedAPIs(apis []DeprecatedAPI) string {
+	formatted := make([]string, len(apis))
+	for i, api := range apis {
+		formatted[i] = fmt.Sprintf("%s/%s (%s)", api.Kind, api.Name, api.APIVersion)
+	}
+	return strings.Join(formatted, "; ")
+}
+
+// formatKubeCompatible reports whether the latest version of a release supports the Kubernetes version, or an
+// empty string if it was not checked
+func (output Output) formatKubeCompatible(release ReleaseOutput) string {
+	if output.KubeVersion == "" || release.Latest.Version == "" {
+		return ""
+	}
+	return strconv.FormatBool(!release.KubeIncompatible)
+}
+
+// printMovedCharts prints a table of the releases whose chart may have been renamed or moved
+func (output Output) printMovedCharts() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 4, ' ', 0)
+	printed := false
+	for _, release := range output.HelmReleases {
+		for _, moved := range release.PossiblyMovedTo {
+			if !printed {
+				fmt.Fprintln(w, "\n\nPossibly Moved Charts:")
+				fmt.Fprintln(w, "Release Name\tChart Name\tPossibly Moved To\tLatest\tReasons")
+				fmt.Fprintln(w, "============\t==========\t=================\t======\t=======")
+				printed = true
+			}
+			fmt.Fprintln(w, release.ReleaseName+"\t"+release.ChartName+"\t"+moved.Repository+"/"+moved.ChartName+"\t"+moved.Version+"\t"+strings.Join(moved.Reasons, ", ")+"\t")
+		}
+	}
+	w.Flush()
+}
+
+// printDeprecatedAPIs prints a table of the objects in release manifests that use deprecated or removed API versions
+func (output Output) printDeprecatedAPIs() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 4, ' ', 0)
+	printed := false
+	for _, release := range output.HelmReleases {
+		for _, api := range release.DeprecatedAPIs {
+			if !printed {
+				fmt.Fprintf(w, "\n\nDeprecated APIs (Kubernetes %s):\n", output.KubeVersion)
+				fmt.Fprintln(w, "Release Name\tNamespace\tKind\tName\tAPI Version\tDeprecated In\tRemoved In\tRemoved\tReplacement")
+				fmt.Fprintln(w, "============\t=========\t====\t====\t===========\t=============\t==========\t=======\t===========")
+				printed = true
+			}
+			fmt.Fprintln(w, release.ReleaseName+"\t"+release.Namespace+"\t"+api.Kind+"\t"+api.Name+"\t"+api.APIVersion+"\t"+api.DeprecatedIn+"\t"+api.RemovedIn+"\t"+strconv.FormatBool(api.Removed)+"\t"+api.Replacement+"\t")
+		}
+	}
+	w.Flush()
+}
+
+// printRepoErrors prints a table of the chart repositories that could not be loaded to out
+func (output Output) printRepoErrors(out io.Writer) {
+	if len(output.RepoErrors) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 4, ' ', 0)
+	fmt.Fprintln(w, "\n\nRepository Errors:")
+	fmt.Fprintln(w, "Repository URL\tStatus Code\tError")
+	fmt.Fprintln(w, "==============\t===========\t=====")
+	for _, e := range output.RepoErrors {
+		statusCode := ""
+		if e.StatusCode != 0 {
+			statusCode = strconv.Itoa(e.StatusCode)
+		}
+		fmt.Fprintln(w, e.URL+"\t"+statusCode+"\t"+e.Error+"\t")
+	}
+	w.Flush()
+}
+
 // Dedupe will remove duplicate releases from the output if both artifacthub and a custom URL to a helm repository find matches.
 // this will always override any found by artifacthub with the version from a custom helm repo url because those are found last and
 // will therefore always be at the end of the output.HelmReleases array.
@@ -226,6 +695,39 @@ func (output *Output) Dedupe() {
 	output.HelmReleases = unique
 }
 
+// SortByVulnerabilities orders the releases by the security report summary of their installed version, with the most
+// critical vulnerabilities first. Releases without a summary keep their order at the end.
+func (output *Output) SortByVulnerabilities() {
+	sort.SliceStable(output.HelmReleases, func(i, j int) bool {
+		return output.HelmReleases[j].Installed.Security.Less(output.HelmReleases[i].Installed.Security)
+	})
+}
+
+// String returns the owner as kind/namespace/name, or an empty string if there is no owner
+func (owner *GitOpsOwner) String() string {
+	if owner == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", owner.Kind, owner.Namespace, owner.Name)
+}
+
+// MergeGitOpsReleases adds releases found via gitops objects to the output. If the release was also found in helm storage,
+// only the owning gitops object is recorded on the existing release.
+func (output *Output) MergeGitOpsReleases(releases []ReleaseOutput) {
+	for _, release := range releases {
+		merged := false
+		for i, existing := range output.HelmReleases {
+			if existing.ReleaseName == release.ReleaseName && existing.ChartName == release.ChartName && existing.Namespace == release.Namespace {
+				output.HelmReleases[i].ManagedBy = release.ManagedBy
+				merged = true
+			}
+		}
+		if !merged {
+			output.HelmReleases = append(output.HelmReleases, release)
+		}
+	}
+}
+
 // NewContainersOutput creates a new ContainersOutput object ready to be printed
 func NewContainersOutput(containers []*containers.Image, errImages []*containers.ErroredImage, showNonSemver, showErrored, includeAll bool) *ContainersOutput {
 	var output ContainersOutput
@@ -333,9 +835,15 @@ func (output ContainersOutput) Print(format string) {
 
 // CombinedOutputFormat has both helm releases and containers info in a backwards compatible way
 type CombinedOutputFormat struct {
-	Helm       []ReleaseOutput `json:"helm"`
-	IncludeAll bool            `json:"include_all"`
-	Container  struct {
+	Helm        []ReleaseOutput `json:"helm"`
+	IncludeAll  bool            `json:"include_all"`
+	AllStatuses bool            `json:"all_statuses"`
+	RepoErrors  []RepoError     `json:"repo_errors,omitempty"`
+	// ArtifactHubCache describes the age of the ArtifactHub package list the releases were matched against
+	ArtifactHubCache *CacheInfo `json:"artifacthub_cache,omitempty"`
+	// KubeVersion is the Kubernetes version the kubeVersion constraints of the latest chart versions were checked against
+	KubeVersion string `json:"kube_version,omitempty"`
+	Container   struct {
 		ContainerImages   []ContainerOutput          `json:"container_images"`
 		ErrImages         []*containers.ErroredImage `json:"err_images"`
 		LatestStringFound bool                       `json:"latest_string_found"`
@@ -369,7 +877,11 @@ func (output HelmAndContainersOutput) Print(format string, wide, showOld bool) {
 				ErrImages:         output.Container.ErrImages,
 				LatestStringFound: output.Container.LatestStringFound,
 			},
-			IncludeAll: output.Helm.IncludeAll,
+			IncludeAll:       output.Helm.IncludeAll,
+			AllStatuses:      output.Helm.AllStatuses,
+			RepoErrors:       output.Helm.RepoErrors,
+			ArtifactHubCache: output.Helm.ArtifactHubCache,
+			KubeVersion:      output.Helm.KubeVersion,
 		}
 		data, _ := marshalWithoutHTMLEscaping(outputFormat)
 		fmt.Fprintln(os.Stdout, string(data))
@@ -392,7 +904,11 @@ func (output HelmAndContainersOutput) ToFile(filename string) error {
 				ErrImages:         output.Container.ErrImages,
 				LatestStringFound: output.Container.LatestStringFound,
 			},
-			IncludeAll: output.Helm.IncludeAll,
+			IncludeAll:       output.Helm.IncludeAll,
+			AllStatuses:      output.Helm.AllStatuses,
+			RepoErrors:       output.Helm.RepoErrors,
+			ArtifactHubCache: output.Helm.ArtifactHubCache,
+			KubeVersion:      output.Helm.KubeVersion,
 		}
 		data, err := marshalWithoutHTMLEscaping(outputFormat)
 		if err != nil {
@@ -409,6 +925,27 @@ func (output HelmAndContainersOutput) ToFile(filename string) error {
 	return nil
 }
 
+// formatMatchScore formats the ArtifactHub match score for table and csv output, marking ambiguous matches and
+// leaving releases that were not matched by score empty
+func formatMatchScore(release ReleaseOutput) string {
+	if release.MatchScore == 0 {
+		return ""
+	}
+	score := strconv.FormatFloat(float64(release.MatchScore), 'g', -1, 32)
+	if release.Ambiguous {
+		score += " (ambiguous)"
+	}
+	return score
+}
+
+// formatTime formats a timestamp for table and csv output, leaving unknown times empty
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
 // marshalWithoutHTMLEscaping encodes v to JSON without escaping HTML characters.
 func marshalWithoutHTMLEscaping(v any) ([]byte, error) {
 	var buf bytes.Buffer
@@ -0,0 +1,165 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseOutput_SetAppDrift(t *testing.T) {
+	tests := []struct {
+		name       string
+		installed  string
+		latest     string
+		want       *AppVersionDrift
+		wantString string
+	}{
+		{
+			name:      "no app version",
+			installed: "",
+			latest:    "1.0.0",
+			want:      nil,
+		},
+		{
+			name:      "unchanged",
+			installed: "1.2.3",
+			latest:    "1.2.3",
+			want:      &AppVersionDrift{},
+		},
+		{
+			name:       "major",
+			installed:  "v1.2.3",
+			latest:     "v3.0.1",
+			want:       &AppVersionDrift{Changed: true, Level: AppDriftMajor, MajorBehind: 2},
+			wantString: "major (2)",
+		},
+		{
+			name:       "minor",
+			installed:  "1.2.3",
+			latest:     "1.5.0",
+			want:       &AppVersionDrift{Changed: true, Level: AppDriftMinor, MinorBehind: 3},
+			wantString: "minor (3)",
+		},
+		{
+			name:       "patch",
+			installed:  "1.2.3",
+			latest:     "1.2.4",
+			want:       &AppVersionDrift{Changed: true, Level: AppDriftPatch, PatchBehind: 1},
+			wantString: "patch (1)",
+		},
+		{
+			name:       "not semver",
+			installed:  "RELEASE.2023-01-02",
+			latest:     "RELEASE.2024-03-04",
+			want:       &AppVersionDrift{Changed: true},
+			wantString: "changed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ReleaseOutput{
+				Installed: VersionInfo{AppVersion: tt.installed},
+				Latest:    VersionInfo{AppVersion: tt.latest},
+			}
+			r.SetAppDrift()
+			assert.Equal(t, tt.want, r.AppDrift)
+			assert.Equal(t, tt.wantString, r.AppDrift.String())
+		})
+	}
+}
+
+func TestReleaseOutput_IsBehind(t *testing.T) {
+	tests := []struct {
+		name        string
+		installed   string
+		latest      string
+		isOld       bool
+		minAppDrift string
+		want        bool
+	}{
+		{name: "chart version", installed: "1.0.0", latest: "1.0.0", isOld: true, want: true},
+		{name: "app unchanged", installed: "1.0.0", latest: "1.0.0", isOld: true, minAppDrift: AppDriftPatch, want: false},
+		{name: "major meets minor", installed: "1.0.0", latest: "2.0.0", minAppDrift: AppDriftMinor, want: true},
+		{name: "patch below minor", installed: "1.0.0", latest: "1.0.1", minAppDrift: AppDriftMinor, want: false},
+		{name: "downgrade", installed: "1.1.0", latest: "1.0.0", minAppDrift: AppDriftPatch, want: false},
+		{name: "not semver counts as patch", installed: "alpha", latest: "beta", minAppDrift: AppDriftPatch, want: true},
+		{name: "not semver below minor", installed: "alpha", latest: "beta", minAppDrift: AppDriftMinor, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ReleaseOutput{
+				Installed: VersionInfo{AppVersion: tt.installed},
+				Latest:    VersionInfo{AppVersion: tt.latest},
+				IsOld:     tt.isOld,
+			}
+			r.SetAppDrift()
+			assert.Equal(t, tt.want, r.IsBehind(tt.minAppDrift))
+		})
+	}
+}
+
+func TestHelmAndContainersOutput_ToFile(t *testing.T) {
+	helm := Output{
+		HelmReleases: []ReleaseOutput{{
+			ReleaseName:             "foo",
+			KubeIncompatible:        true,
+			LatestCompatibleVersion: "1.2.0",
+		}},
+		AllStatuses: true,
+		KubeVersion: "1.29.0",
+	}
+	filename := filepath.Join(t.TempDir(), "nova.json")
+	err := NewHelmAndContainersOutput(helm, ContainersOutput{}).ToFile(filename)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	var got map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, true, got["all_statuses"])
+	assert.Equal(t, "1.29.0", got["kube_version"])
+	release := got["helm"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, true, release["kubeIncompatible"])
+	assert.Equal(t, "1.2.0", release["latestCompatibleVersion"])
+}
+
+func TestOutput_ToFileCSVColumns(t *testing.T) {
+	readHeader := func(out Output) []string {
+		filename := filepath.Join(t.TempDir(), "nova.csv")
+		assert.NoError(t, out.ToFile(filename))
+		file, err := os.Open(filename)
+		assert.NoError(t, err)
+		defer file.Close()
+		header, err := csv.NewReader(file).Read()
+		assert.NoError(t, err)
+		return header
+	}
+	original := []string{"Release Name", "Chart Name", "Namespace", "HelmVersion", "Installed", "Latest", "Old", "Deprecated"}
+
+	assert.Equal(t, original, readHeader(Output{}))
+	assert.Equal(t, append(original, "Status", "Last Deployed"), readHeader(Output{AllStatuses: true}))
+
+	wide := readHeader(Output{Wide: true, KubeVersion: "1.29.0", Vulnerabilities: true, AllStatuses: true})
+	assert.Equal(t, original, wide[:len(original)])
+	assert.Equal(t, []string{"Status", "Last Deployed", "Deprecated APIs"}, wide[len(wide)-3:])
+	assert.Contains(t, wide, "Kube Compatible")
+	assert.Contains(t, wide, "Installed Vulnerabilities")
+}
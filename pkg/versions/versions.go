@@ -0,0 +1,78 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package versions compares helm chart and container image versions with the same semantic versioning rules.
+package versions
+
+import (
+	"slices"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// prereleaseIdentifiers start the pre-release part of versions that are not final releases, e.g. 1.0.0-rc.1 or
+// 2.0.0-beta3. Other pre-release parts, like the variant in 1.2.3-alpine, do not make a version a pre-release.
+var prereleaseIdentifiers = []string{"alpha", "a", "beta", "b", "rc", "pre", "preview", "prerelease", "dev", "snapshot", "nightly", "weekly", "daily", "canary", "unstable"}
+
+// Parse parses a version, accepting a "v" prefix and a missing minor or patch version
+func Parse(v string) (*semver.Version, error) {
+	return semver.NewVersion(v)
+}
+
+// IsPrerelease reports whether a version is a pre-release like 1.0.0-rc.1. Build metadata, e.g. 1.0.0+build.5,
+// and variants, e.g. 1.2.3-alpine, are not pre-releases.
+func IsPrerelease(v *semver.Version) bool {
+	prerelease := strings.ToLower(v.Prerelease())
+	if prerelease == "" {
+		return false
+	}
+	identifier := strings.TrimLeftFunc(prerelease, func(r rune) bool { return r < 'a' || r > 'z' })
+	end := strings.IndexFunc(identifier, func(r rune) bool { return r < 'a' || r > 'z' })
+	if end >= 0 {
+		identifier = identifier[:end]
+	}
+	return slices.Contains(prereleaseIdentifiers, identifier)
+}
+
+// IsValid reports whether a version can be suggested as an upgrade: it must be a semantic version, and not a
+// pre-release unless includePrereleases is set.
+func IsValid(v string, includePrereleases bool) bool {
+	parsed, err := Parse(v)
+	if err != nil {
+		return false
+	}
+	return includePrereleases || !IsPrerelease(parsed)
+}
+
+// Compare returns -1, 0 or 1 if a is older than, the same as, or newer than b. Build metadata is ignored.
+// Versions that cannot be parsed are older than any that can, and are compared with each other as strings.
+func Compare(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.Compare(vb)
+}
+
+// Newer reports whether version a is newer than version b
+func Newer(a, b string) bool {
+	return Compare(a, b) > 0
+}
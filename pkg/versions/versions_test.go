@@ -0,0 +1,81 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValid(t *testing.T) {
+	tests := []struct {
+		version            string
+		includePrereleases bool
+		want               bool
+	}{
+		{"v1.0", false, true},
+		{"1.2.3", false, true},
+		{"1.2.3+build.5", false, true},
+		{"1.2.3-alpine", false, true},
+		{"1.2.3-bookworm", false, true},
+		{"1.0-rc3", false, false},
+		{"1.0-rc3", true, true},
+		{"2.0.0-beta.1", false, false},
+		{"2.0.0-alpha", false, false},
+		{"2.0.0-SNAPSHOT", false, false},
+		{"2.0.0-0.3.7", false, true},
+		{"latest", false, false},
+		{"latest", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValid(tt.version, tt.includePrereleases))
+		})
+	}
+}
+
+func TestIsPrerelease(t *testing.T) {
+	for version, want := range map[string]bool{
+		"1.0.0-alpha":    true,
+		"1.0.0-alphax":   false,
+		"1.0.0-foobar":   false,
+		"1.0.0-rc.1":     true,
+		"1.0.0-b2":       true,
+		"1.0.0-buster":   false,
+		"1.0.0-1.beta":   true,
+		"1.0.0+beta":     false,
+		"1.0.0":          false,
+		"1.0.0-dev.1234": true,
+	} {
+		t.Run(version, func(t *testing.T) {
+			v, err := Parse(version)
+			assert.NoError(t, err)
+			assert.Equal(t, want, IsPrerelease(v))
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	assert.Equal(t, 1, Compare("1.10.0", "1.9.0"))
+	assert.Equal(t, -1, Compare("v1.2.3", "1.2.4"))
+	assert.Equal(t, 0, Compare("1.2.3+build.1", "1.2.3+build.2"))
+	assert.Equal(t, -1, Compare("1.2.3-rc.1", "1.2.3"))
+	assert.Equal(t, 1, Compare("1.0.0", ""))
+	assert.Equal(t, -1, Compare("not-a-version", "0.0.1"))
+	assert.Equal(t, 0, Compare("", ""))
+	assert.True(t, Newer("2.0.0", "1.99.99"))
+	assert.False(t, Newer("1.0.0", "1.0.0"))
+}